@@ -4,6 +4,8 @@ package main
 
 import (
 	"log"
+	"math"
+	"slices"
 
 	"golang.org/x/exp/rand"
 
@@ -15,6 +17,20 @@ type mass struct {
 	d r2.Vec  // position
 	v r2.Vec  // velocity
 	m float64 // mass
+
+	trail []r2.Vec // recent positions, oldest first
+	into  *mass    // the star this one merged into, if any
+}
+
+// params configures the physics of the simulation.
+type params struct {
+	// collide is the distance within which two stars merge into one.
+	// Collisions are disabled when collide is zero.
+	collide float64
+	// soften is the gravitational softening length. It bounds the
+	// force between stars at close range, which would otherwise grow
+	// without limit.
+	soften float64
 }
 
 func (m *mass) Coord2() r2.Vec { return m.d }
@@ -29,7 +45,18 @@ func (m *mass) move(f r2.Vec) {
 	m.d = m.d.Add(m.v)
 }
 
-func galaxy(numStars int, rnd *rand.Rand) ([]*mass, barneshut.Plane) {
+// record appends the current position to the trail, discarding the
+// oldest positions beyond n.
+func (m *mass) record(n int) {
+	if len(m.trail) < n {
+		m.trail = append(m.trail, m.d)
+		return
+	}
+	copy(m.trail, m.trail[1:])
+	m.trail[n-1] = m.d
+}
+
+func galaxy(numStars int, rnd *rand.Rand) ([]*mass, *barneshut.Plane) {
 	// Make 50 stars in random locations and velocities.
 	stars := make([]*mass, numStars)
 	p := make([]barneshut.Particle2, len(stars))
@@ -51,12 +78,56 @@ func galaxy(numStars int, rnd *rand.Rand) ([]*mass, barneshut.Plane) {
 		p[i] = s
 	}
 	// Make a plane to calculate approximate forces
-	plane := barneshut.Plane{Particles: p}
+	plane := &barneshut.Plane{Particles: p}
 
 	return stars, plane
 }
 
-func simulate(stars []*mass, plane barneshut.Plane, dist *distribution) {
+// softGravity returns a force function like barneshut.Gravity2, but
+// with the distance between the particles softened by eps so that the
+// force is (m1⋅m2)/(‖v‖²+eps²) at most.
+func softGravity(eps float64) barneshut.Force2 {
+	eps2 := eps * eps
+	return func(_, _ barneshut.Particle2, m1, m2 float64, v r2.Vec) r2.Vec {
+		d2 := v.X*v.X + v.Y*v.Y
+		if d2 == 0 {
+			return r2.Vec{}
+		}
+		d2 += eps2
+		return v.Scale((m1 * m2) / (d2 * math.Sqrt(d2)))
+	}
+}
+
+// merge combines b into a, conserving mass and momentum. The merged
+// star is placed at the center of mass of the pair.
+func merge(a, b *mass) {
+	m := a.m + b.m
+	a.d = a.d.Scale(a.m).Add(b.d.Scale(b.m)).Scale(1 / m)
+	a.v = a.v.Scale(a.m).Add(b.v.Scale(b.m)).Scale(1 / m)
+	a.m = m
+	b.into = a
+}
+
+// collide merges every pair of stars closer than radius and returns
+// the surviving stars. Of each pair, the heavier star survives.
+func collide(stars []*mass, radius float64) []*mass {
+	collisions(len(stars), radius, func(i int) [3]float64 {
+		d := stars[i].d
+		return [3]float64{d.X, d.Y}
+	}, func(i, j int) int {
+		if stars[j].m > stars[i].m {
+			merge(stars[j], stars[i])
+			return j
+		}
+		merge(stars[i], stars[j])
+		return i
+	})
+	return slices.DeleteFunc(stars, func(s *mass) bool { return s.into != nil })
+}
+
+// simulate advances the simulation by one step and returns the stars
+// that remain after any collisions.
+func simulate(stars []*mass, plane *barneshut.Plane, dist *distribution, p params) []*mass {
 	vectors := make([]r2.Vec, len(stars))
 	// Build the data structure. For small systems
 	// this step may be omitted and ForceOn will
@@ -72,8 +143,9 @@ func simulate(stars []*mass, plane barneshut.Plane, dist *distribution) {
 	const theta = 0.1
 	// and an imaginary gravitational constant.
 	const G = 10
+	force := softGravity(p.soften)
 	for j, s := range stars {
-		vectors[j] = plane.ForceOn(s, theta, barneshut.Gravity2).Scale(G)
+		vectors[j] = plane.ForceOn(s, theta, force).Scale(G)
 	}
 
 	// Update positions.
@@ -81,7 +153,23 @@ func simulate(stars []*mass, plane barneshut.Plane, dist *distribution) {
 		s.move(vectors[j])
 	}
 
+	// Merge colliding stars, and keep the plane in sync with the
+	// survivors.
+	if n := len(stars); n > 0 {
+		stars = collide(stars, p.collide)
+		if len(stars) != n {
+			for i := len(stars); i < n; i++ {
+				plane.Particles[i] = nil
+			}
+			plane.Particles = plane.Particles[:len(stars)]
+			for i, s := range stars {
+				plane.Particles[i] = s
+			}
+		}
+	}
+
 	// Recompute the distribution of stars
 	dist.Update(stars)
 	dist.EnsureSquare()
+	return stars
 }
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"log"
+	"math"
+	"slices"
+
+	"golang.org/x/exp/rand"
+
+	"gonum.org/v1/gonum/spatial/barneshut"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// mass3 is the three-dimensional counterpart of mass.
+type mass3 struct {
+	d r3.Vec  // position
+	v r3.Vec  // velocity
+	m float64 // mass
+
+	into *mass3 // the star this one merged into, if any
+}
+
+func (m *mass3) Coord3() r3.Vec { return m.d }
+func (m *mass3) Mass() float64  { return m.m }
+func (m *mass3) move(f r3.Vec) {
+	// F = ma
+	m.v = m.v.Add(f.Scale(1 / m.m))
+
+	// Update position.
+	m.d = m.d.Add(m.v)
+}
+
+// galaxy3 is like galaxy, but places the stars in a volume.
+func galaxy3(numStars int, rnd *rand.Rand) ([]*mass3, *barneshut.Volume) {
+	stars := make([]*mass3, numStars)
+	p := make([]barneshut.Particle3, len(stars))
+	for i := range stars {
+		s := &mass3{
+			d: r3.Vec{
+				X: 100*rnd.Float64() - 50,
+				Y: 100*rnd.Float64() - 50,
+				Z: 100*rnd.Float64() - 50,
+			},
+			m: rnd.Float64(),
+		}
+		// Aim at the ground and miss.
+		s.d = s.d.Scale(-1).Add(r3.Vec{
+			X: 10 * rnd.NormFloat64(),
+			Y: 10 * rnd.NormFloat64(),
+			Z: 10 * rnd.NormFloat64(),
+		})
+
+		stars[i] = s
+		p[i] = s
+	}
+	// Make a volume to calculate approximate forces
+	volume := &barneshut.Volume{Particles: p}
+
+	return stars, volume
+}
+
+// softGravity3 is the three-dimensional counterpart of softGravity.
+func softGravity3(eps float64) barneshut.Force3 {
+	eps2 := eps * eps
+	return func(_, _ barneshut.Particle3, m1, m2 float64, v r3.Vec) r3.Vec {
+		d2 := v.X*v.X + v.Y*v.Y + v.Z*v.Z
+		if d2 == 0 {
+			return r3.Vec{}
+		}
+		d2 += eps2
+		return v.Scale((m1 * m2) / (d2 * math.Sqrt(d2)))
+	}
+}
+
+// merge3 combines b into a, conserving mass and momentum.
+func merge3(a, b *mass3) {
+	m := a.m + b.m
+	a.d = a.d.Scale(a.m).Add(b.d.Scale(b.m)).Scale(1 / m)
+	a.v = a.v.Scale(a.m).Add(b.v.Scale(b.m)).Scale(1 / m)
+	a.m = m
+	b.into = a
+}
+
+// collide3 is the three-dimensional counterpart of collide.
+func collide3(stars []*mass3, radius float64) []*mass3 {
+	collisions(len(stars), radius, func(i int) [3]float64 {
+		d := stars[i].d
+		return [3]float64{d.X, d.Y, d.Z}
+	}, func(i, j int) int {
+		if stars[j].m > stars[i].m {
+			merge3(stars[j], stars[i])
+			return j
+		}
+		merge3(stars[i], stars[j])
+		return i
+	})
+	return slices.DeleteFunc(stars, func(s *mass3) bool { return s.into != nil })
+}
+
+// simulate3 is the three-dimensional counterpart of simulate.
+func simulate3(stars []*mass3, volume *barneshut.Volume, p params) []*mass3 {
+	vectors := make([]r3.Vec, len(stars))
+	err := volume.Reset()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	const theta = 0.1
+	const G = 10
+	force := softGravity3(p.soften)
+	for j, s := range stars {
+		vectors[j] = volume.ForceOn(s, theta, force).Scale(G)
+	}
+
+	for j, s := range stars {
+		s.move(vectors[j])
+	}
+
+	if n := len(stars); n > 0 {
+		stars = collide3(stars, p.collide)
+		if len(stars) != n {
+			for i := len(stars); i < n; i++ {
+				volume.Particles[i] = nil
+			}
+			volume.Particles = volume.Particles[:len(stars)]
+			for i, s := range stars {
+				volume.Particles[i] = s
+			}
+		}
+	}
+	return stars
+}
+
+// centerOfMass returns the mass-weighted mean position of the stars,
+// along with their total mass.
+func centerOfMass(stars []*mass3) (r3.Vec, float64) {
+	var (
+		c r3.Vec
+		m float64
+	)
+	for _, s := range stars {
+		c = c.Add(s.d.Scale(s.m))
+		m += s.m
+	}
+	if m == 0 {
+		return r3.Vec{}, 0
+	}
+	return c.Scale(1 / m), m
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/spatial/r2"
+	"gonum.org/v1/gonum/spatial/r3"
+)
+
+// momentum returns the total mass and momentum of the stars.
+func momentum(stars []*mass) (float64, r2.Vec) {
+	var (
+		m float64
+		p r2.Vec
+	)
+	for _, s := range stars {
+		m += s.m
+		p = p.Add(s.v.Scale(s.m))
+	}
+	return m, p
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*max(1, math.Abs(a), math.Abs(b))
+}
+
+func TestMerge(t *testing.T) {
+	a := &mass{d: r2.Vec{X: 0, Y: 0}, v: r2.Vec{X: 1, Y: 0}, m: 3}
+	b := &mass{d: r2.Vec{X: 4, Y: 0}, v: r2.Vec{X: 0, Y: 2}, m: 1}
+	m0, p0 := momentum([]*mass{a, b})
+	merge(a, b)
+	m1, p1 := momentum([]*mass{a})
+	if !closeTo(m0, m1) || !closeTo(p0.X, p1.X) || !closeTo(p0.Y, p1.Y) {
+		t.Errorf("merge: mass %v momentum %v, want %v %v", m1, p1, m0, p0)
+	}
+	if want := (r2.Vec{X: 1, Y: 0}); a.d != want {
+		t.Errorf("merge: position %v, want the center of mass %v", a.d, want)
+	}
+	if b.into != a {
+		t.Errorf("merge: b.into = %p, want %p", b.into, a)
+	}
+}
+
+func TestCollide(t *testing.T) {
+	tests := []struct {
+		name      string
+		stars     []*mass
+		radius    float64
+		survivors int
+	}{
+		{
+			name: "apart",
+			stars: []*mass{
+				{d: r2.Vec{X: 0}, v: r2.Vec{X: 1}, m: 1},
+				{d: r2.Vec{X: 10}, v: r2.Vec{X: -1}, m: 2},
+			},
+			radius:    1,
+			survivors: 2,
+		},
+		{
+			name: "pair",
+			stars: []*mass{
+				{d: r2.Vec{X: 0}, v: r2.Vec{X: 1}, m: 1},
+				{d: r2.Vec{X: 0.5}, v: r2.Vec{Y: -1}, m: 2},
+			},
+			radius:    1,
+			survivors: 1,
+		},
+		{
+			name: "across cells",
+			stars: []*mass{
+				{d: r2.Vec{X: -0.1, Y: -0.1}, v: r2.Vec{X: 3}, m: 0.5},
+				{d: r2.Vec{X: 0.1, Y: 0.1}, v: r2.Vec{Y: 3}, m: 0.25},
+				{d: r2.Vec{X: 5, Y: 5}, v: r2.Vec{X: -2, Y: 1}, m: 1},
+			},
+			radius:    1,
+			survivors: 2,
+		},
+		{
+			name: "cluster",
+			stars: []*mass{
+				{d: r2.Vec{X: 0}, v: r2.Vec{X: 1}, m: 1},
+				{d: r2.Vec{X: 0.2}, v: r2.Vec{X: -1}, m: 1},
+				{d: r2.Vec{Y: 0.2}, v: r2.Vec{Y: 2}, m: 3},
+				{d: r2.Vec{X: 0.1, Y: 0.1}, v: r2.Vec{Y: -4}, m: 0.5},
+			},
+			radius:    1,
+			survivors: 1,
+		},
+		{
+			name: "disabled",
+			stars: []*mass{
+				{d: r2.Vec{X: 0}, m: 1},
+				{d: r2.Vec{X: 0}, m: 1},
+			},
+			radius:    0,
+			survivors: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m0, p0 := momentum(test.stars)
+			stars := collide(test.stars, test.radius)
+			if len(stars) != test.survivors {
+				t.Fatalf("%d survivors, want %d", len(stars), test.survivors)
+			}
+			m1, p1 := momentum(stars)
+			if !closeTo(m0, m1) {
+				t.Errorf("total mass %v, want %v", m1, m0)
+			}
+			if !closeTo(p0.X, p1.X) || !closeTo(p0.Y, p1.Y) {
+				t.Errorf("total momentum %v, want %v", p1, p0)
+			}
+		})
+	}
+}
+
+func TestScaleEqualMasses(t *testing.T) {
+	stars := []*mass{{d: r2.Vec{X: 1, Y: 1}, v: r2.Vec{X: 2, Y: 2}, m: 5}}
+	var d distribution
+	d.Update(stars)
+	// Mergers leave a single mass, so the mass range is zero.
+	d.maxMass = d.minMass
+	size := float64(d.Scale(stars[0]).Size)
+	if math.IsNaN(size) || math.IsInf(size, 0) || size <= 0 {
+		t.Errorf("size %v for equal masses", size)
+	}
+}
+
+func TestCollide3(t *testing.T) {
+	stars := []*mass3{
+		{d: r3.Vec{Z: -0.1}, v: r3.Vec{X: 1}, m: 1},
+		{d: r3.Vec{Z: 0.1}, v: r3.Vec{Z: 2}, m: 3},
+		// Near in X and Y, but not in Z.
+		{d: r3.Vec{X: 0.1, Z: 5}, v: r3.Vec{Y: 1}, m: 2},
+	}
+	stars = collide3(stars, 1)
+	if len(stars) != 2 {
+		t.Fatalf("%d survivors, want 2", len(stars))
+	}
+	com, m := centerOfMass(stars)
+	if want := (r3.Vec{X: 0.2 / 6, Z: (0.2 + 10) / 6}); !closeTo(m, 6) || !closeTo(com.X, want.X) || !closeTo(com.Z, want.Z) {
+		t.Errorf("center of mass %v of mass %v, want %v of mass 6", com, m, want)
+	}
+	if s := stars[0]; s.m != 4 || !closeTo(s.v.X, 0.25) || !closeTo(s.v.Z, 1.5) {
+		t.Errorf("merged star of mass %v, velocity %v", s.m, s.v)
+	}
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// colormap maps values between 0 and 1 to colors by interpolating
+// between evenly spaced stops.
+type colormap struct {
+	name  string
+	stops []color.NRGBA
+}
+
+// colormaps lists the colormaps available to the heatmap. The first is
+// the default.
+var colormaps = []colormap{
+	{
+		name: "Inferno",
+		stops: []color.NRGBA{
+			{R: 0x00, G: 0x00, B: 0x04, A: 0xff},
+			{R: 0x42, G: 0x0a, B: 0x68, A: 0xff},
+			{R: 0x93, G: 0x26, B: 0x67, A: 0xff},
+			{R: 0xdd, G: 0x51, B: 0x3a, A: 0xff},
+			{R: 0xfc, G: 0xa5, B: 0x0a, A: 0xff},
+			{R: 0xfc, G: 0xff, B: 0xa4, A: 0xff},
+		},
+	},
+	{
+		name: "Viridis",
+		stops: []color.NRGBA{
+			{R: 0x44, G: 0x01, B: 0x54, A: 0xff},
+			{R: 0x41, G: 0x44, B: 0x87, A: 0xff},
+			{R: 0x2a, G: 0x78, B: 0x8e, A: 0xff},
+			{R: 0x22, G: 0xa8, B: 0x84, A: 0xff},
+			{R: 0x7a, G: 0xd1, B: 0x51, A: 0xff},
+			{R: 0xfd, G: 0xe7, B: 0x25, A: 0xff},
+		},
+	},
+	{
+		name: "Gray",
+		stops: []color.NRGBA{
+			{R: 0x00, G: 0x00, B: 0x00, A: 0xff},
+			{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		},
+	},
+}
+
+// at returns the color for v, which is clamped to [0, 1].
+func (c colormap) at(v float64) color.NRGBA {
+	v = max(0, min(v, 1))
+	pos := v * float64(len(c.stops)-1)
+	i := min(int(pos), len(c.stops)-2)
+	t := pos - float64(i)
+	a, b := c.stops[i], c.stops[i+1]
+	lerp := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t + .5)
+	}
+	return color.NRGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: 0xff}
+}
+
+// heatmap renders the stars as an image of their mass density rather
+// than as individual dots. The square spanned by the distribution is
+// divided into a grid of cells, and each cell is colored according to
+// the total mass of the stars within it. Zooming in shows the cells in
+// view larger, rather than binning the stars again.
+type heatmap struct {
+	// perCell is the mean number of stars per cell that the resolution
+	// of the grid aims for.
+	perCell float64
+	// logScale compresses the range of densities logarithmically,
+	// which keeps sparse regions visible next to dense cores.
+	logScale bool
+	cmap     colormap
+
+	bins []float64
+	img  *image.RGBA
+}
+
+const (
+	// minHeatCells and maxHeatCells bound the cells on each side of the
+	// heatmap grid.
+	minHeatCells = 32
+	maxHeatCells = 512
+)
+
+// cells returns the number of cells on each side of the grid over the
+// distribution.
+func (h *heatmap) cells(dist distribution) int {
+	perCell := h.perCell
+	if perCell <= 0 {
+		perCell = 1
+	}
+	n := int(math.Sqrt(float64(dist.count) / perCell))
+	return max(minHeatCells, min(n, maxHeatCells))
+}
+
+// Layout bins the stars of the distribution and paints the cells
+// within view over the whole of gtx.Constraints.Max.
+func (h *heatmap) Layout(gtx C, stars []*mass, dist distribution, view *viewport) D {
+	size := gtx.Constraints.Max
+	if size.X <= 0 || size.Y <= 0 {
+		return D{Size: size}
+	}
+	v := fullView
+	if view != nil {
+		v = *view
+	}
+	n := h.cells(dist)
+	// Bin only the cells in view, [x0, x1)×[y0, y1).
+	clamp := func(c int) int { return max(0, min(c, n)) }
+	x0 := clamp(int(math.Floor(float64(v.offset.X) * float64(n))))
+	y0 := clamp(int(math.Floor(float64(v.offset.Y) * float64(n))))
+	x1 := clamp(int(math.Ceil(float64(v.offset.X+v.size.X) * float64(n))))
+	y1 := clamp(int(math.Ceil(float64(v.offset.Y+v.size.Y) * float64(n))))
+	cols, rows := x1-x0, y1-y0
+	if cols <= 0 || rows <= 0 {
+		return D{Size: size}
+	}
+	if len(h.bins) != cols*rows {
+		h.bins = make([]float64, cols*rows)
+	} else {
+		for i := range h.bins {
+			h.bins[i] = 0
+		}
+	}
+	for _, s := range stars {
+		p := dist.point(s.d)
+		// Stars on the far edges of the distribution belong to the
+		// last cells.
+		x := min(int(p.X*float32(n)), n-1)
+		y := min(int(p.Y*float32(n)), n-1)
+		if x < x0 || x >= x1 || y < y0 || y >= y1 {
+			continue
+		}
+		h.bins[(y-y0)*cols+x-x0] += s.m
+	}
+	var peak float64
+	for _, b := range h.bins {
+		peak = max(peak, b)
+	}
+	scale := func(b float64) float64 {
+		if peak == 0 {
+			return 0
+		}
+		if h.logScale {
+			return math.Log1p(b) / math.Log1p(peak)
+		}
+		return b / peak
+	}
+
+	if h.img == nil || h.img.Rect.Dx() != cols || h.img.Rect.Dy() != rows {
+		h.img = image.NewRGBA(image.Rect(0, 0, cols, rows))
+	}
+	cmap := h.cmap
+	if len(cmap.stops) == 0 {
+		cmap = colormaps[0]
+	}
+	for i, b := range h.bins {
+		c := cmap.at(scale(b))
+		o := i * 4
+		// The colors are opaque, so there is no need to premultiply.
+		h.img.Pix[o+0] = c.R
+		h.img.Pix[o+1] = c.G
+		h.img.Pix[o+2] = c.B
+		h.img.Pix[o+3] = c.A
+	}
+
+	defer clip.Rect(image.Rectangle{Max: size}).Push(gtx.Ops).Pop()
+	imgOp := paint.NewImageOp(h.img)
+	imgOp.Filter = paint.FilterLinear
+	imgOp.Add(gtx.Ops)
+	// Map the cells to the screen through the view.
+	sx := float32(size.X) / (float32(n) * v.size.X)
+	sy := float32(size.Y) / (float32(n) * v.size.Y)
+	origin := f32.Pt(
+		(float32(x0)/float32(n)-v.offset.X)/v.size.X*float32(size.X),
+		(float32(y0)/float32(n)-v.offset.Y)/v.size.Y*float32(size.Y),
+	)
+	tr := f32.Affine2D{}.Scale(f32.Point{}, f32.Pt(sx, sy)).Offset(origin)
+	defer op.Affine(tr).Push(gtx.Ops).Pop()
+	defer clip.Rect(h.img.Rect).Push(gtx.Ops).Pop()
+	paint.PaintOp{}.Add(gtx.Ops)
+	return D{Size: size}
+}
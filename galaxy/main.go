@@ -3,6 +3,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
@@ -20,6 +21,7 @@ import (
 	"gioui.org/f32"
 	"gioui.org/font/gofont"
 	"gioui.org/io/event"
+	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/op"
@@ -38,6 +40,8 @@ type distribution struct {
 	maxSpeed         float64
 	meanSpeed        float64
 	minMass, maxMass float64
+	totalMass        float64
+	count            int
 
 	speedSum     float64
 	speedSamples int
@@ -50,6 +54,8 @@ func (d *distribution) Update(stars []*mass) {
 		speedSum     float64
 		speedSamples int
 	)
+	d.totalMass = 0
+	d.count = len(stars)
 	for i, s := range stars {
 		speed := distance(s.v, s.d)
 		if i == 0 {
@@ -78,6 +84,7 @@ func (d *distribution) Update(stars []*mass) {
 		}
 		speedSamples++
 		speedSum += speed
+		d.totalMass += s.m
 	}
 	d.meanSpeed = speedSum / float64(speedSamples)
 }
@@ -102,21 +109,36 @@ func (d *distribution) EnsureSquare() {
 
 // String describes the distribution in text form.
 func (d distribution) String() string {
-	return fmt.Sprintf("distance: (min: %v max: %v), mass: (min: %v, max: %v)", d.min, d.max, d.minMass, d.maxMass)
+	return fmt.Sprintf("distance: (min: %v max: %v), mass: (min: %v, max: %v, total: %v), stars: %d", d.min, d.max, d.minMass, d.maxMass, d.totalMass, d.count)
 }
 
 // Scale uses the min/max data within the distribution to compute the
 // position, speed, and size of the star.
 func (d distribution) Scale(star *mass) Star {
 	s := Star{}
-	s.X = float32((star.d.X - d.min.X) / (d.max.X - d.min.X))
-	s.Y = float32((star.d.Y - d.min.Y) / (d.max.Y - d.min.Y))
+	p := d.point(star.d)
+	s.X, s.Y = p.X, p.Y
 	speed := math.Log(distance(star.v, star.d)) / math.Log(d.maxSpeed)
 	s.Speed = float32(speed)
-	s.Size = unit.Dp(float32(1 + ((star.m / (d.maxMass - d.minMass)) * 10)))
+	// Once mergers leave stars of a single mass, there is no range to
+	// scale their sizes by.
+	size := 6.0
+	if r := d.maxMass - d.minMass; r > 0 {
+		size = 1 + star.m/r*10
+	}
+	s.Size = unit.Dp(float32(size))
 	return s
 }
 
+// point scales a position in space to the unit square spanned by the
+// distribution.
+func (d distribution) point(v r2.Vec) f32.Point {
+	return f32.Point{
+		X: float32((v.X - d.min.X) / (d.max.X - d.min.X)),
+		Y: float32((v.Y - d.min.Y) / (d.max.Y - d.min.Y)),
+	}
+}
+
 // distance implements the simple two-dimensional euclidean distance function.
 func distance(a, b r2.Vec) float64 {
 	return math.Sqrt((b.X-a.X)*(b.X-a.X) + (b.Y-a.Y)*(b.Y-a.Y))
@@ -137,47 +159,65 @@ var ClearIcon = func() *widget.Icon {
 	return ic
 }()
 
-// viewport models a region of a larger space. Offset is the location
-// of the upper-left corner of the view within the larger space. size
-// is the dimensions of the viewport within the larger space.
-type viewport struct {
-	offset f32.Point
-	size   f32.Point
-}
+var BackIcon = func() *widget.Icon {
+	ic, _ := widget.NewIcon(icons.NavigationArrowBack)
+	return ic
+}()
 
-// subview modifies v to describe a smaller region by zooming into the
-// space described by v using other.
-func (v *viewport) subview(other *viewport) {
-	v.offset.X += other.offset.X * v.size.X
-	v.offset.Y += other.offset.Y * v.size.Y
-	v.size.X *= other.size.X
-	v.size.Y *= other.size.Y
-}
+var TrailsIcon = func() *widget.Icon {
+	ic, _ := widget.NewIcon(icons.ActionTimeline)
+	return ic
+}()
 
-// ensureSquare returns a copy of the rectangle that has been padded to
-// be square by increasing the maximum coordinate.
-func ensureSquare(r image.Rectangle) image.Rectangle {
-	dx := r.Dx()
-	dy := r.Dy()
-	if dx > dy {
-		r.Max.Y = r.Min.Y + dx
-	} else if dy > dx {
-		r.Max.X = r.Min.X + dy
-	}
-	return r
-}
+var TrackIcon = func() *widget.Icon {
+	ic, _ := widget.NewIcon(icons.MapsMyLocation)
+	return ic
+}()
+
+var HeatmapIcon = func() *widget.Icon {
+	ic, _ := widget.NewIcon(icons.ImageGrain)
+	return ic
+}()
+
+var CloseIcon = func() *widget.Icon {
+	ic, _ := widget.NewIcon(icons.NavigationClose)
+	return ic
+}()
 
 var (
-	ops         op.Ops
-	play, clear widget.Clickable
-	playing     = false
-	th          = material.NewTheme()
-	selected    image.Rectangle
-	selecting   = false
-	view        *viewport
+	ops               op.Ops
+	play, clear, back widget.Clickable
+	crumbs            [maxHistory + 1]widget.Clickable
+	playing           = false
+	th                = material.NewTheme()
+	selected          image.Rectangle
+	selecting         = false
+	panning, panned   = false, false
+	lastDrag          f32.Point
+	lastScroll        time.Time
+	cam               = newCamera()
+
+	trails, track, deselect widget.Clickable
+	showTrails, tracking    = false, false
+	// selectedStar is the star being inspected, if any.
+	selectedStar *mass
+
+	heatToggle  widget.Clickable
+	showHeatmap = false
+	cmapChoice  = widget.Enum{Value: colormaps[0].name}
+	logScale    = widget.Bool{Value: true}
+	heat        = heatmap{perCell: 1}
+)
+
+var (
+	numStars  = flag.Int("stars", 1000, "number of stars")
+	collision = flag.Float64("collide", 0.5, "distance within which stars merge, or 0 to disable collisions")
+	softening = flag.Float64("soften", 0.5, "gravitational softening length")
+	threeD    = flag.Bool("3d", false, "simulate the galaxy in three dimensions")
 )
 
 func main() {
+	flag.Parse()
 	th.Shaper = text.NewShaper(text.WithCollection(gofont.Collection()))
 	th.Palette.Fg, th.Palette.Bg = th.Palette.Bg, th.Palette.Fg
 	dist := distribution{}
@@ -185,9 +225,10 @@ func main() {
 	seed := time.Now().UnixNano()
 	rnd := rand.New(rand.NewSource(uint64(seed)))
 
-	// Make 1000 stars in random locations.
-	stars, plane := galaxy(1000, rnd)
-	dist.Update(stars)
+	physics := params{
+		collide: *collision,
+		soften:  *softening,
+	}
 
 	desiredSize := unit.Dp(800)
 	window := new(app.Window)
@@ -196,11 +237,30 @@ func main() {
 		app.Title("Seed: "+strconv.Itoa(int(seed))),
 	)
 
+	if *threeD {
+		stars, volume := galaxy3(*numStars, rnd)
+		run3D(window, stars, volume, physics)
+		return
+	}
+
+	// Make stars in random locations.
+	stars, plane := galaxy(*numStars, rnd)
+	dist.Update(stars)
+
 	iterateSim := func() {
 		if !playing {
 			return
 		}
-		simulate(stars, plane, &dist)
+		stars = simulate(stars, plane, &dist, physics)
+		// Follow the selected star through any mergers.
+		for selectedStar != nil && selectedStar.into != nil {
+			selectedStar = selectedStar.into
+		}
+		if showTrails {
+			for _, s := range stars {
+				s.record(trailLength)
+			}
+		}
 		window.Invalidate()
 	}
 	for {
@@ -227,18 +287,68 @@ func main() {
 					gtx.Constraints.Min = gtx.Constraints.Max
 
 					if clear.Clicked(gtx) {
-						view = nil
+						cam.reset(gtx)
+						tracking = false
+					}
+					if back.Clicked(gtx) {
+						cam.back(gtx)
+					}
+					for i := range cam.history {
+						if crumbs[i].Clicked(gtx) {
+							cam.restore(gtx, i)
+						}
 					}
 					if play.Clicked(gtx) {
 						playing = !playing
 					}
+					if trails.Clicked(gtx) {
+						showTrails = !showTrails
+						if !showTrails {
+							for _, s := range stars {
+								s.trail = nil
+							}
+						}
+					}
+					if track.Clicked(gtx) {
+						tracking = !tracking
+					}
+					if heatToggle.Clicked(gtx) {
+						showHeatmap = !showHeatmap
+					}
+					if cmapChoice.Update(gtx) {
+						for _, c := range colormaps {
+							if c.name == cmapChoice.Value {
+								heat.cmap = c
+							}
+						}
+					}
+					logScale.Update(gtx)
+					heat.logScale = logScale.Value
+					if deselect.Clicked(gtx) {
+						selectedStar = nil
+						tracking = false
+					}
 
-					layoutSelectionLayer(gtx)
+					layoutSelectionLayer(gtx, stars, dist)
+					if tracking && selectedStar != nil {
+						cam.follow(dist.point(selectedStar.d))
+					}
+					view := cam.update(gtx)
 
-					for _, s := range stars {
-						dist.Scale(s).Layout(gtx, view)
+					if showHeatmap {
+						heat.Layout(gtx, stars, dist, view)
+					} else {
+						if showTrails {
+							layoutTrails(gtx, stars, dist, view)
+						}
+						layoutStars(gtx, stars, dist, view)
 					}
-					layoutControls(gtx)
+					if selectedStar != nil {
+						layoutHighlight(gtx, selectedStar, dist, view)
+					}
+					layoutControls(gtx, dist)
+					layoutStarInfo(gtx)
+					layoutHeatmapSettings(gtx)
 					return D{Size: gtx.Constraints.Max}
 				})
 			})
@@ -249,42 +359,217 @@ func main() {
 	}
 }
 
-func layoutControls(gtx C) D {
+func layoutControls(gtx C, dist distribution) D {
 	layout.N.Layout(gtx, func(gtx C) D {
-		return material.Body1(th, "Click and drag to zoom in on a region").Layout(gtx)
+		return layout.Flex{
+			Axis:      layout.Vertical,
+			Alignment: layout.Middle,
+		}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return material.Body1(th, "Scroll to zoom, drag to pan, shift-drag to zoom in on a region").Layout(gtx)
+			}),
+			layout.Rigid(layoutBreadcrumbs),
+		)
 	})
 	layout.S.Layout(gtx, func(gtx C) D {
 		gtx.Constraints.Min.X = gtx.Constraints.Max.X
 		return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
 			return layout.Flex{
-				Spacing: layout.SpaceEvenly,
+				Axis:      layout.Vertical,
+				Alignment: layout.Middle,
 			}.Layout(gtx,
 				layout.Rigid(func(gtx C) D {
-					var btn material.IconButtonStyle
-					if playing {
-						btn = material.IconButton(th, &play, PauseIcon, "Pause Simulation")
-					} else {
-						btn = material.IconButton(th, &play, PlayIcon, "Play Simulation")
-					}
-					return btn.Layout(gtx)
-				}),
-				layout.Rigid(func(gtx C) D {
-					if view == nil {
-						gtx = gtx.Disabled()
-					}
-					return material.IconButton(th, &clear, ClearIcon, "Reset Viewport").Layout(gtx)
+					stats := fmt.Sprintf("Stars: %d  Total mass: %.2f", dist.count, dist.totalMass)
+					return material.Body2(th, stats).Layout(gtx)
 				}),
+				layout.Rigid(layoutButtons),
 			)
 		})
 	})
 	return D{}
 }
 
-func layoutSelectionLayer(gtx C) D {
+func layoutButtons(gtx C) D {
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return layout.Flex{
+		Spacing: layout.SpaceEvenly,
+	}.Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			var btn material.IconButtonStyle
+			if playing {
+				btn = material.IconButton(th, &play, PauseIcon, "Pause Simulation")
+			} else {
+				btn = material.IconButton(th, &play, PlayIcon, "Play Simulation")
+			}
+			return btn.Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if len(cam.history) == 0 {
+				gtx = gtx.Disabled()
+			}
+			return material.IconButton(th, &back, BackIcon, "Previous Viewport").Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if !cam.zoomed() {
+				gtx = gtx.Disabled()
+			}
+			return material.IconButton(th, &clear, ClearIcon, "Reset Viewport").Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if showHeatmap {
+				gtx = gtx.Disabled()
+			}
+			btn := material.IconButton(th, &trails, TrailsIcon, "Toggle Trails")
+			if showTrails {
+				btn.Background = th.ContrastBg
+			} else {
+				btn.Background = th.Fg
+			}
+			return btn.Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			btn := material.IconButton(th, &heatToggle, HeatmapIcon, "Toggle Density Heatmap")
+			if showHeatmap {
+				btn.Background = th.ContrastBg
+			} else {
+				btn.Background = th.Fg
+			}
+			return btn.Layout(gtx)
+		}),
+	)
+}
+
+// layoutBreadcrumbs displays the camera history as a row of buttons
+// labelled with their magnification. Clicking one returns to that
+// viewport.
+func layoutBreadcrumbs(gtx C) D {
+	if len(cam.history) == 0 {
+		return D{}
+	}
+	children := make([]layout.FlexChild, 0, len(cam.history)+1)
+	crumb := func(gtx C, btn *widget.Clickable, v viewport) D {
+		return layout.UniformInset(unit.Dp(2)).Layout(gtx, func(gtx C) D {
+			b := material.Button(th, btn, magnification(v))
+			b.Inset = layout.UniformInset(unit.Dp(4))
+			b.TextSize = unit.Sp(12)
+			return b.Layout(gtx)
+		})
+	}
+	for i, v := range cam.history {
+		children = append(children, layout.Rigid(func(gtx C) D {
+			return crumb(gtx, &crumbs[i], v)
+		}))
+	}
+	children = append(children, layout.Rigid(func(gtx C) D {
+		return crumb(gtx.Disabled(), &crumbs[len(cam.history)], cam.to)
+	}))
+	return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+}
+
+// magnification describes how far v is zoomed in relative to fullView.
+func magnification(v viewport) string {
+	m := 1 / v.size.X
+	if m < 10 {
+		return fmt.Sprintf("%.1fx", m)
+	}
+	return fmt.Sprintf("%.0fx", m)
+}
+
+// layoutStarInfo displays the mass, position and velocity of the
+// selected star, along with controls to track or deselect it.
+func layoutStarInfo(gtx C) D {
+	if selectedStar == nil {
+		return D{}
+	}
+	s := selectedStar
+	return layout.NW.Layout(gtx, func(gtx C) D {
+		return layout.Inset{Top: unit.Dp(48), Left: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+			return layoutPanel(gtx, func(gtx C) D {
+				line := func(format string, args ...any) layout.FlexChild {
+					return layout.Rigid(material.Body2(th, fmt.Sprintf(format, args...)).Layout)
+				}
+				speed := math.Hypot(s.v.X, s.v.Y)
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+					line("Mass: %.4f", s.m),
+					line("Position: (%.2f, %.2f)", s.d.X, s.d.Y),
+					line("Velocity: (%.3f, %.3f)", s.v.X, s.v.Y),
+					line("Speed: %.3f", speed),
+					layout.Rigid(func(gtx C) D {
+						return layout.Flex{}.Layout(gtx,
+							layout.Rigid(func(gtx C) D {
+								btn := material.IconButton(th, &track, TrackIcon, "Track Star")
+								if tracking {
+									btn.Background = th.ContrastBg
+								} else {
+									btn.Background = th.Fg
+								}
+								btn.Size = unit.Dp(16)
+								btn.Inset = layout.UniformInset(unit.Dp(6))
+								return btn.Layout(gtx)
+							}),
+							layout.Rigid(layout.Spacer{Width: unit.Dp(8)}.Layout),
+							layout.Rigid(func(gtx C) D {
+								btn := material.IconButton(th, &deselect, CloseIcon, "Deselect Star")
+								btn.Size = unit.Dp(16)
+								btn.Inset = layout.UniformInset(unit.Dp(6))
+								return btn.Layout(gtx)
+							}),
+						)
+					}),
+				)
+			})
+		})
+	})
+}
+
+// layoutPanel draws w on a translucent background with rounded corners.
+func layoutPanel(gtx C, w layout.Widget) D {
+	return layout.Background{}.Layout(gtx,
+		func(gtx C) D {
+			bg := th.Palette.Bg
+			bg.A = 0xd0
+			rr := gtx.Dp(4)
+			paint.FillShape(gtx.Ops, bg, clip.UniformRRect(image.Rectangle{Max: gtx.Constraints.Min}, rr).Op(gtx.Ops))
+			return D{Size: gtx.Constraints.Min}
+		},
+		func(gtx C) D {
+			return layout.UniformInset(unit.Dp(8)).Layout(gtx, w)
+		},
+	)
+}
+
+// layoutHeatmapSettings displays the colormap and scaling options of
+// the heatmap while it is shown.
+func layoutHeatmapSettings(gtx C) D {
+	if !showHeatmap {
+		return D{}
+	}
+	return layout.NE.Layout(gtx, func(gtx C) D {
+		return layout.Inset{Top: unit.Dp(48), Right: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+			return layoutPanel(gtx, func(gtx C) D {
+				children := []layout.FlexChild{
+					layout.Rigid(material.Body2(th, "Colormap").Layout),
+				}
+				for _, c := range colormaps {
+					children = append(children, layout.Rigid(
+						material.RadioButton(th, &cmapChoice, c.name, c.name).Layout,
+					))
+				}
+				children = append(children, layout.Rigid(
+					material.CheckBox(th, &logScale, "Log scale").Layout,
+				))
+				return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+			})
+		})
+	})
+}
+
+func layoutSelectionLayer(gtx C, stars []*mass, dist distribution) D {
 	for {
 		event, ok := gtx.Event(pointer.Filter{
-			Target: &selected,
-			Kinds:  pointer.Press | pointer.Release | pointer.Drag,
+			Target:  &selected,
+			Kinds:   pointer.Press | pointer.Release | pointer.Drag | pointer.Scroll,
+			ScrollY: pointer.ScrollRange{Min: -math.MaxInt32, Max: math.MaxInt32},
 		})
 		if !ok {
 			break
@@ -294,12 +579,45 @@ func layoutSelectionLayer(gtx C) D {
 			var intPt image.Point
 			intPt.X = int(event.Position.X)
 			intPt.Y = int(event.Position.Y)
+			size := f32.Pt(float32(gtx.Constraints.Max.X), float32(gtx.Constraints.Max.Y))
 			switch event.Kind {
+			case pointer.Scroll:
+				// Treat scrolls in quick succession as a single zoom
+				// for the purposes of the history.
+				if gtx.Now.Sub(lastScroll) > 500*time.Millisecond {
+					cam.push()
+				}
+				lastScroll = gtx.Now
+				at := f32.Pt(event.Position.X/size.X, event.Position.Y/size.Y)
+				cam.zoom(gtx, at, float32(math.Exp(float64(event.Scroll.Y)/100)))
 			case pointer.Press:
-				selecting = true
-				selected.Min = intPt
-				selected.Max = intPt
+				if event.Modifiers.Contain(key.ModShift) {
+					selecting = true
+					selected.Min = intPt
+					selected.Max = intPt
+				} else {
+					panning = true
+					panned = false
+					lastDrag = event.Position
+				}
 			case pointer.Drag:
+				if panning {
+					// Only remember the viewport once the pointer
+					// actually moves, so that plain clicks leave the
+					// history alone.
+					if !panned {
+						panned = true
+						tracking = false
+						cam.push()
+					}
+					delta := event.Position.Sub(lastDrag)
+					lastDrag = event.Position
+					cam.pan(f32.Pt(delta.X/size.X, delta.Y/size.Y))
+					break
+				}
+				if !selecting {
+					break
+				}
 				if intPt.X >= selected.Min.X && intPt.Y >= selected.Min.Y {
 					selected.Max = intPt
 				} else {
@@ -307,6 +625,22 @@ func layoutSelectionLayer(gtx C) D {
 				}
 				selected = ensureSquare(selected)
 			case pointer.Release:
+				if panning {
+					panning = false
+					if !panned {
+						// A click without dragging selects the star
+						// under the pointer.
+						selectedStar = starAt(gtx, stars, dist, &cam.view, event.Position)
+						if selectedStar == nil {
+							tracking = false
+						}
+					}
+					break
+				}
+				if !selecting || selected.Dx() == 0 || selected.Dy() == 0 {
+					selecting = false
+					break
+				}
 				selecting = false
 				newView := &viewport{
 					offset: f32.Point{
@@ -318,13 +652,10 @@ func layoutSelectionLayer(gtx C) D {
 						Y: float32(selected.Dy()) / float32(gtx.Constraints.Max.Y),
 					},
 				}
-				if view == nil {
-					view = newView
-				} else {
-					view.subview(newView)
-				}
+				cam.subview(gtx, newView)
 			case pointer.Cancel:
 				selecting = false
+				panning = false
 				selected = image.Rectangle{}
 			}
 		}
@@ -333,50 +664,18 @@ func layoutSelectionLayer(gtx C) D {
 		paint.FillShape(gtx.Ops, color.NRGBA{R: 255, A: 100}, clip.Rect(selected).Op())
 	}
 	pr := clip.Rect(image.Rectangle{Max: gtx.Constraints.Max}).Push(gtx.Ops)
-	pointer.CursorCrosshair.Add(gtx.Ops)
+	if panning {
+		pointer.CursorGrabbing.Add(gtx.Ops)
+	} else {
+		pointer.CursorCrosshair.Add(gtx.Ops)
+	}
 	event.Op(gtx.Ops, &selected)
 	pr.Pop()
 
 	return D{Size: gtx.Constraints.Max}
 }
 
-// Star represents a point of mass rendered within a specific region of a canvas.
-type Star struct {
-	X, Y  float32
-	Speed float32
-	Size  unit.Dp
-}
-
 type (
 	C = layout.Context
 	D = layout.Dimensions
 )
-
-// Layout renders the star into the gtx assuming that it is visible within the
-// provided viewport. Stars outside of the viewport will be skipped.
-func (s Star) Layout(gtx layout.Context, view *viewport) layout.Dimensions {
-	px := gtx.Dp(s.Size)
-	if view != nil {
-		if s.X < view.offset.X || s.X > view.offset.X+view.size.X {
-			return D{}
-		}
-		if s.Y < view.offset.Y || s.Y > view.offset.Y+view.size.Y {
-			return D{}
-		}
-		s.X = (s.X - view.offset.X) / view.size.X
-		s.Y = (s.Y - view.offset.Y) / view.size.Y
-	}
-	rr := px / 2
-	x := s.X*float32(gtx.Constraints.Max.X) - float32(rr)
-	y := s.Y*float32(gtx.Constraints.Max.Y) - float32(rr)
-	defer op.Affine(f32.Affine2D{}.Offset(f32.Pt(x, y))).Push(gtx.Ops).Pop()
-
-	rect := image.Rectangle{
-		Max: image.Pt(px, px),
-	}
-	fill := color.NRGBA{R: 0xff, G: 128, B: 0xff, A: 50}
-	fill.R = 255 - uint8(255*s.Speed)
-	fill.B = uint8(255 * s.Speed)
-	paint.FillShape(gtx.Ops, fill, clip.UniformRRect(rect, rr).Op(gtx.Ops))
-	return D{}
-}
@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"slices"
+
+	"gonum.org/v1/gonum/spatial/barneshut"
+	"gonum.org/v1/gonum/spatial/r3"
+
+	"gioui.org/app"
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+const (
+	// depthBands is the number of depth shades used to draw stars in
+	// three dimensions. Stars in the same band are drawn with a single
+	// path.
+	depthBands = 16
+	// fieldOfView is the vertical field of view of the orbit camera,
+	// in radians.
+	fieldOfView = math.Pi / 3
+	// nearPlane is the closest distance from the camera at which stars
+	// are drawn.
+	nearPlane = 1
+)
+
+// orbit is a perspective camera that circles around a target point.
+type orbit struct {
+	target     r3.Vec
+	yaw, pitch float64 // rotation around the vertical and horizontal axes
+	distance   float64 // distance from the target
+	drag       f32.Point
+}
+
+// defaultOrbit is the camera position when the 3D simulation starts.
+var defaultOrbit = orbit{pitch: 0.4, distance: 250}
+
+// project returns the position of p on a screen of the given size and
+// its depth, the distance from the camera along the view axis. ok is
+// false when p is behind the camera.
+func (o *orbit) project(p r3.Vec, size image.Point) (pos f32.Point, depth float64, ok bool) {
+	p = p.Sub(o.target)
+	// Rotate around the vertical axis, then around the horizontal one.
+	sy, cy := math.Sincos(o.yaw)
+	p.X, p.Z = cy*p.X-sy*p.Z, sy*p.X+cy*p.Z
+	sp, cp := math.Sincos(o.pitch)
+	p.Y, p.Z = cp*p.Y-sp*p.Z, sp*p.Y+cp*p.Z
+
+	depth = p.Z + o.distance
+	if depth < nearPlane {
+		return f32.Point{}, 0, false
+	}
+	w, h := float64(size.X), float64(size.Y)
+	focal := h / 2 / math.Tan(fieldOfView/2)
+	pos = f32.Point{
+		X: float32(w/2 + focal*p.X/depth),
+		Y: float32(h/2 - focal*p.Y/depth),
+	}
+	return pos, depth, true
+}
+
+// zoom moves the camera towards or away from its target by factor.
+func (o *orbit) zoom(factor float64) {
+	o.distance = max(10, min(o.distance*factor, 5000))
+}
+
+// rotate orbits the camera by delta pixels of pointer movement.
+func (o *orbit) rotate(delta f32.Point) {
+	const speed = 0.01
+	o.yaw += float64(delta.X) * speed
+	o.pitch += float64(delta.Y) * speed
+	o.pitch = max(-math.Pi/2, min(o.pitch, math.Pi/2))
+}
+
+// projected is a star transformed into screen space.
+type projected struct {
+	pos   f32.Point
+	depth float64
+	size  float32
+}
+
+// depthColor shades stars from bright and opaque when nearest the
+// camera (t = 0) to dim and translucent when farthest (t = 1).
+func depthColor(t float32) color.NRGBA {
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float32(a) + (float32(b)-float32(a))*t)
+	}
+	return color.NRGBA{
+		R: lerp(0xff, 0x50),
+		G: lerp(0xe0, 0x60),
+		B: lerp(0xc0, 0xa0),
+		A: lerp(0xe0, 0x30),
+	}
+}
+
+// layoutStars3 renders the stars through the orbit camera. The stars
+// are sorted from back to front so that nearer stars are drawn over
+// farther ones, and are shaded by depth in bands that each form a
+// single path.
+func layoutStars3(gtx C, stars []*mass3, cam *orbit, buf []projected) ([]projected, D) {
+	size := gtx.Constraints.Max
+	defer clip.Rect(image.Rectangle{Max: size}).Push(gtx.Ops).Pop()
+	var maxMass float64
+	for _, s := range stars {
+		maxMass = max(maxMass, s.m)
+	}
+	buf = buf[:0]
+	for _, s := range stars {
+		pos, depth, ok := cam.project(s.d, size)
+		if !ok {
+			continue
+		}
+		px := float32(gtx.Dp(unit.Dp(1 + s.m/maxMass*10)))
+		// Scale with perspective, so that stars at the target
+		// distance have their natural size, but keep stars that
+		// pass close to the camera from covering the screen.
+		px = min(px*float32(cam.distance/depth), float32(gtx.Dp(48)))
+		buf = append(buf, projected{pos: pos, depth: depth, size: px})
+	}
+	if len(buf) == 0 {
+		return buf, D{Size: size}
+	}
+	slices.SortFunc(buf, func(a, b projected) int {
+		return cmp.Compare(b.depth, a.depth)
+	})
+	near, far := buf[len(buf)-1].depth, buf[0].depth
+	band := func(p projected) int {
+		if far == near {
+			return 0
+		}
+		t := (far - p.depth) / (far - near)
+		return min(int(t*depthBands), depthBands-1)
+	}
+	for i := 0; i < len(buf); {
+		b := band(buf[i])
+		var p clip.Path
+		p.Begin(gtx.Ops)
+		for ; i < len(buf) && band(buf[i]) == b; i++ {
+			addCircle(&p, buf[i].pos, max(buf[i].size/2, .5))
+		}
+		t := 1 - (float32(b)+.5)/depthBands
+		paint.FillShape(gtx.Ops, depthColor(t), clip.Outline{Path: p.End()}.Op())
+	}
+	return buf, D{Size: size}
+}
+
+// run3D runs the three-dimensional simulation in window until it is
+// closed.
+func run3D(window *app.Window, stars []*mass3, volume *barneshut.Volume, physics params) {
+	var (
+		cam      = defaultOrbit
+		recenter widget.Clickable
+		buf      []projected
+		dragging bool
+	)
+	cam.target, _ = centerOfMass(stars)
+	iterateSim := func() {
+		if !playing {
+			return
+		}
+		stars = simulate3(stars, volume, physics)
+		window.Invalidate()
+	}
+	for {
+		switch ev := window.Event().(type) {
+		case app.DestroyEvent:
+			if ev.Err != nil {
+				log.Fatal(ev.Err)
+			}
+			return
+		case app.FrameEvent:
+			gtx := app.NewContext(&ops, ev)
+			paint.Fill(gtx.Ops, th.Palette.Bg)
+
+			if play.Clicked(gtx) {
+				playing = !playing
+			}
+			if recenter.Clicked(gtx) {
+				cam = defaultOrbit
+			}
+			for {
+				ev, ok := gtx.Event(pointer.Filter{
+					Target:  &cam,
+					Kinds:   pointer.Press | pointer.Release | pointer.Drag | pointer.Scroll,
+					ScrollY: pointer.ScrollRange{Min: -math.MaxInt32, Max: math.MaxInt32},
+				})
+				if !ok {
+					break
+				}
+				e, ok := ev.(pointer.Event)
+				if !ok {
+					continue
+				}
+				switch e.Kind {
+				case pointer.Press:
+					dragging = true
+					cam.drag = e.Position
+				case pointer.Release, pointer.Cancel:
+					dragging = false
+				case pointer.Drag:
+					cam.rotate(e.Position.Sub(cam.drag))
+					cam.drag = e.Position
+				case pointer.Scroll:
+					cam.zoom(math.Exp(float64(e.Scroll.Y) / 100))
+				}
+			}
+			// Keep the camera on the center of mass as the stars move.
+			com, total := centerOfMass(stars)
+			cam.target = com
+
+			layout.Center.Layout(gtx, func(gtx C) D {
+				return widget.Border{
+					Color: th.Fg,
+					Width: unit.Dp(1),
+				}.Layout(gtx, func(gtx C) D {
+					if gtx.Constraints.Max.X > gtx.Constraints.Max.Y {
+						gtx.Constraints.Max.X = gtx.Constraints.Max.Y
+					} else {
+						gtx.Constraints.Max.Y = gtx.Constraints.Max.X
+					}
+					gtx.Constraints.Min = gtx.Constraints.Max
+
+					pr := clip.Rect(image.Rectangle{Max: gtx.Constraints.Max}).Push(gtx.Ops)
+					if dragging {
+						pointer.CursorGrabbing.Add(gtx.Ops)
+					} else {
+						pointer.CursorGrab.Add(gtx.Ops)
+					}
+					event.Op(gtx.Ops, &cam)
+					pr.Pop()
+
+					buf, _ = layoutStars3(gtx, stars, &cam, buf)
+
+					layout.N.Layout(gtx, material.Body1(th, "Drag to orbit, scroll to zoom").Layout)
+					layout.S.Layout(gtx, func(gtx C) D {
+						return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+							return layout.Flex{
+								Axis:      layout.Vertical,
+								Alignment: layout.Middle,
+							}.Layout(gtx,
+								layout.Rigid(func(gtx C) D {
+									stats := fmt.Sprintf("Stars: %d  Total mass: %.2f", len(stars), total)
+									return material.Body2(th, stats).Layout(gtx)
+								}),
+								layout.Rigid(func(gtx C) D {
+									return layout.Flex{Spacing: layout.SpaceEvenly}.Layout(gtx,
+										layout.Rigid(func(gtx C) D {
+											var btn material.IconButtonStyle
+											if playing {
+												btn = material.IconButton(th, &play, PauseIcon, "Pause Simulation")
+											} else {
+												btn = material.IconButton(th, &play, PlayIcon, "Play Simulation")
+											}
+											return btn.Layout(gtx)
+										}),
+										layout.Rigid(layout.Spacer{Width: unit.Dp(16)}.Layout),
+										layout.Rigid(func(gtx C) D {
+											return material.IconButton(th, &recenter, ClearIcon, "Reset Camera").Layout(gtx)
+										}),
+									)
+								}),
+							)
+						})
+					})
+					return D{Size: gtx.Constraints.Max}
+				})
+			})
+
+			ev.Frame(gtx.Ops)
+			iterateSim()
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import "math"
+
+// collisions merges the stars closer than radius to each other, for
+// both the 2D and the 3D simulations. There are n stars, and pos
+// returns the position of star i, with z zero for stars in a plane.
+// merge(i, j) merges the lighter of stars i and j into the heavier, and
+// returns the index of the heavier. Stars are bucketed into a grid of
+// cells the size of radius, so that only stars in neighboring cells
+// need to be compared.
+func collisions(n int, radius float64, pos func(i int) [3]float64, merge func(i, j int) int) {
+	if radius <= 0 {
+		return
+	}
+	type cell struct{ x, y, z int64 }
+	cellOf := func(i int) cell {
+		p := pos(i)
+		return cell{
+			int64(math.Floor(p[0] / radius)),
+			int64(math.Floor(p[1] / radius)),
+			int64(math.Floor(p[2] / radius)),
+		}
+	}
+	grid := make(map[cell][]int, n)
+	// Planar stars need not look at the cells above and below.
+	dz := int64(0)
+	for i := range n {
+		c := cellOf(i)
+		grid[c] = append(grid[c], i)
+		if c.z != 0 {
+			dz = 1
+		}
+	}
+	r2 := radius * radius
+	gone := make([]bool, n)
+next:
+	for i := range n {
+		if gone[i] {
+			continue
+		}
+		c := cellOf(i)
+		for x := c.x - 1; x <= c.x+1; x++ {
+			for y := c.y - 1; y <= c.y+1; y++ {
+				for z := c.z - dz; z <= c.z+dz; z++ {
+					for _, j := range grid[cell{x, y, z}] {
+						if j == i || gone[j] {
+							continue
+						}
+						p, q := pos(i), pos(j)
+						dx, dy, dz := q[0]-p[0], q[1]-p[1], q[2]-p[2]
+						if dx*dx+dy*dy+dz*dz > r2 {
+							continue
+						}
+						if merge(i, j) == j {
+							gone[i] = true
+							continue next
+						}
+						gone[j] = true
+					}
+				}
+			}
+		}
+	}
+}
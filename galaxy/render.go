@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+)
+
+const (
+	// speedBuckets is the number of distinct colors used to draw stars.
+	// Stars sharing a color are drawn with a few paths.
+	speedBuckets = 16
+	// starLayers is the number of paths each color is split into. The
+	// stars are translucent, and stars overlapping in different paths
+	// add up, so that dense regions look brighter as if every star were
+	// drawn on its own.
+	starLayers = 4
+	// trailLength is the number of past positions remembered for each
+	// star when trails are enabled.
+	trailLength = 48
+	// pickRadius is how close a click must be to a star to select it.
+	pickRadius = unit.Dp(8)
+)
+
+// Star represents a point of mass rendered within a specific region of a canvas.
+type Star struct {
+	X, Y  float32
+	Speed float32
+	Size  unit.Dp
+}
+
+// starColor returns the color of a star moving at the given relative
+// speed, from red for the slowest to blue for the fastest.
+func starColor(speed float32) color.NRGBA {
+	return color.NRGBA{
+		R: 255 - uint8(255*speed),
+		G: 128,
+		B: uint8(255 * speed),
+		A: 50,
+	}
+}
+
+// bucket returns the color bucket of the star.
+func (s Star) bucket() int {
+	speed := s.Speed
+	if !(speed > 0) {
+		// Also catches NaN, which occurs for stationary stars.
+		speed = 0
+	}
+	return min(int(speed*speedBuckets), speedBuckets-1)
+}
+
+// project returns the position in pixels of the normalized point p
+// when displayed through view in gtx. ok is false if p falls outside
+// the view.
+func project(gtx C, view *viewport, p f32.Point) (pos f32.Point, ok bool) {
+	if view != nil {
+		if p.X < view.offset.X || p.X > view.offset.X+view.size.X {
+			return f32.Point{}, false
+		}
+		if p.Y < view.offset.Y || p.Y > view.offset.Y+view.size.Y {
+			return f32.Point{}, false
+		}
+		p.X = (p.X - view.offset.X) / view.size.X
+		p.Y = (p.Y - view.offset.Y) / view.size.Y
+	}
+	return f32.Pt(p.X*float32(gtx.Constraints.Max.X), p.Y*float32(gtx.Constraints.Max.Y)), true
+}
+
+// addCircle appends a circle to p.
+func addCircle(p *clip.Path, center f32.Point, r float32) {
+	p.MoveTo(center.Add(f32.Pt(r, 0)))
+	p.Arc(f32.Pt(-r, 0), f32.Pt(-r, 0), 2*math.Pi)
+	p.Close()
+}
+
+// layoutStars renders the stars that are visible within view. Rather
+// than painting every star individually, the stars are grouped by
+// color and each group is drawn in starLayers paths.
+func layoutStars(gtx C, stars []*mass, dist distribution, view *viewport) D {
+	defer clip.Rect(image.Rectangle{Max: gtx.Constraints.Max}).Push(gtx.Ops).Pop()
+	var buckets [speedBuckets][]Star
+	for _, m := range stars {
+		s := dist.Scale(m)
+		b := s.bucket()
+		buckets[b] = append(buckets[b], s)
+	}
+	for i, bucket := range buckets {
+		if len(bucket) == 0 {
+			continue
+		}
+		speed := (float32(i) + .5) / speedBuckets
+		col := starColor(speed)
+		// Overlapping stars of the same path are painted once, so
+		// deal the stars out to several paths.
+		for layer := range starLayers {
+			var p clip.Path
+			p.Begin(gtx.Ops)
+			for j := layer; j < len(bucket); j += starLayers {
+				s := bucket[j]
+				pos, ok := project(gtx, view, f32.Pt(s.X, s.Y))
+				if !ok {
+					continue
+				}
+				addCircle(&p, pos, float32(gtx.Dp(s.Size))/2)
+			}
+			paint.FillShape(gtx.Ops, col, clip.Outline{Path: p.End()}.Op())
+		}
+	}
+	return D{Size: gtx.Constraints.Max}
+}
+
+// layoutTrails renders the recent trajectories of the stars as lines
+// that fade with age. Segments of the same age share a path.
+func layoutTrails(gtx C, stars []*mass, dist distribution, view *viewport) D {
+	defer clip.Rect(image.Rectangle{Max: gtx.Constraints.Max}).Push(gtx.Ops).Pop()
+	width := float32(gtx.Dp(1))
+	// Trails are stored oldest first, so later segments are drawn more
+	// opaque.
+	for i := 1; i < trailLength; i++ {
+		var p clip.Path
+		p.Begin(gtx.Ops)
+		for _, s := range stars {
+			if i >= len(s.trail) {
+				continue
+			}
+			// Clip segments that leave the view rather than skipping
+			// them, so that trails do not stop short of the edge.
+			a := viewPoint(gtx, view, dist.point(s.trail[i-1]))
+			b := viewPoint(gtx, view, dist.point(s.trail[i]))
+			p.MoveTo(a)
+			p.LineTo(b)
+		}
+		alpha := uint8(80 * i / trailLength)
+		paint.FillShape(gtx.Ops, color.NRGBA{R: 0xc0, G: 0xc0, B: 0xff, A: alpha},
+			clip.Stroke{Path: p.End(), Width: width}.Op())
+	}
+	return D{Size: gtx.Constraints.Max}
+}
+
+// viewPoint is like project, but does not reject points outside view.
+func viewPoint(gtx C, view *viewport, p f32.Point) f32.Point {
+	if view != nil {
+		p.X = (p.X - view.offset.X) / view.size.X
+		p.Y = (p.Y - view.offset.Y) / view.size.Y
+	}
+	return f32.Pt(p.X*float32(gtx.Constraints.Max.X), p.Y*float32(gtx.Constraints.Max.Y))
+}
+
+// layoutHighlight draws a ring around the star m.
+func layoutHighlight(gtx C, m *mass, dist distribution, view *viewport) D {
+	s := dist.Scale(m)
+	pos, ok := project(gtx, view, f32.Pt(s.X, s.Y))
+	if !ok {
+		return D{}
+	}
+	var p clip.Path
+	p.Begin(gtx.Ops)
+	addCircle(&p, pos, float32(gtx.Dp(s.Size))/2+float32(gtx.Dp(4)))
+	paint.FillShape(gtx.Ops, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xc0},
+		clip.Stroke{Path: p.End(), Width: float32(gtx.Dp(1.5))}.Op())
+	return D{}
+}
+
+// starAt returns the visible star nearest to the pixel position pos,
+// or nil if there is none within pickRadius.
+func starAt(gtx C, stars []*mass, dist distribution, view *viewport, pos f32.Point) *mass {
+	var (
+		best     *mass
+		bestDist = float32(gtx.Dp(pickRadius))
+	)
+	for _, m := range stars {
+		s := dist.Scale(m)
+		p, ok := project(gtx, view, f32.Pt(s.X, s.Y))
+		if !ok {
+			continue
+		}
+		d := p.Sub(pos)
+		r := float32(math.Hypot(float64(d.X), float64(d.Y))) - float32(gtx.Dp(s.Size))/2
+		if r <= bestDist {
+			best, bestDist = m, r
+		}
+	}
+	return best
+}
@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+)
+
+// viewport models a region of a larger space. Offset is the location
+// of the upper-left corner of the view within the larger space. size
+// is the dimensions of the viewport within the larger space.
+type viewport struct {
+	offset f32.Point
+	size   f32.Point
+}
+
+// fullView is the viewport describing the whole of the larger space.
+var fullView = viewport{size: f32.Pt(1, 1)}
+
+const (
+	// minViewSize and maxViewSize bound how far the viewport may be
+	// zoomed in and out.
+	minViewSize = 1e-4
+	maxViewSize = 8
+)
+
+// subview modifies v to describe a smaller region by zooming into the
+// space described by v using other.
+func (v *viewport) subview(other *viewport) {
+	v.offset.X += other.offset.X * v.size.X
+	v.offset.Y += other.offset.Y * v.size.Y
+	v.size.X *= other.size.X
+	v.size.Y *= other.size.Y
+}
+
+// zoom scales v by factor while keeping the point at fixed, where at
+// is expressed relative to v (so that (0.5, 0.5) is its center).
+// Factors below 1 zoom in, factors above 1 zoom out.
+func (v *viewport) zoom(at f32.Point, factor float32) {
+	size := v.size.Mul(factor)
+	if size.X < minViewSize || size.Y < minViewSize || size.X > maxViewSize || size.Y > maxViewSize {
+		return
+	}
+	pivot := v.offset.Add(f32.Pt(at.X*v.size.X, at.Y*v.size.Y))
+	v.size = size
+	v.offset = pivot.Sub(f32.Pt(at.X*v.size.X, at.Y*v.size.Y))
+}
+
+// pan moves the content within v by delta, expressed relative to v.
+func (v *viewport) pan(delta f32.Point) {
+	v.offset.X -= delta.X * v.size.X
+	v.offset.Y -= delta.Y * v.size.Y
+}
+
+// center returns the middle of v within the larger space.
+func (v viewport) center() f32.Point {
+	return v.offset.Add(v.size.Mul(0.5))
+}
+
+// interpolate returns the viewport that is fraction t of the way from
+// v to to. The size changes geometrically so that the apparent speed
+// of a zoom stays constant, while the center moves linearly.
+func (v viewport) interpolate(to viewport, t float32) viewport {
+	geom := func(a, b float32) float32 {
+		return a * float32(math.Pow(float64(b/a), float64(t)))
+	}
+	c0, c1 := v.center(), to.center()
+	var r viewport
+	r.size = f32.Pt(geom(v.size.X, to.size.X), geom(v.size.Y, to.size.Y))
+	c := c0.Add(c1.Sub(c0).Mul(t))
+	r.offset = c.Sub(r.size.Mul(0.5))
+	return r
+}
+
+// ensureSquare returns a copy of the rectangle that has been padded to
+// be square by increasing the maximum coordinate.
+func ensureSquare(r image.Rectangle) image.Rectangle {
+	dx := r.Dx()
+	dy := r.Dy()
+	if dx > dy {
+		r.Max.Y = r.Min.Y + dx
+	} else if dy > dx {
+		r.Max.X = r.Min.X + dy
+	}
+	return r
+}
+
+const (
+	// transitionDuration is how long the camera takes to animate
+	// between viewports.
+	transitionDuration = 300 * time.Millisecond
+	// maxHistory limits the number of previous viewports remembered.
+	maxHistory = 32
+)
+
+// camera manages the viewport shown on screen. Changes of viewport are
+// animated, and previous viewports are kept in a history so that the
+// user can step back through them.
+type camera struct {
+	// view is the viewport currently being displayed.
+	view viewport
+	// from and to are the endpoints of the running transition, and
+	// start is when it began. start is zero when no transition is
+	// running, in which case view equals to.
+	from, to viewport
+	start    time.Time
+	// history holds previously visited viewports, oldest first.
+	history []viewport
+}
+
+func newCamera() camera {
+	return camera{view: fullView, to: fullView}
+}
+
+// zoomed reports whether the camera has moved away from fullView or
+// has any history to step back through.
+func (c *camera) zoomed() bool {
+	return c.to != fullView || len(c.history) > 0
+}
+
+// push records the current target viewport in the history.
+func (c *camera) push() {
+	if n := len(c.history); n > 0 && c.history[n-1] == c.to {
+		return
+	}
+	c.history = append(c.history, c.to)
+	if len(c.history) > maxHistory {
+		c.history = c.history[len(c.history)-maxHistory:]
+	}
+}
+
+// animate starts a transition from the displayed viewport to v.
+func (c *camera) animate(gtx C, v viewport) {
+	c.from = c.view
+	c.to = v
+	c.start = gtx.Now
+	gtx.Execute(op.InvalidateCmd{})
+}
+
+// jump moves the camera to v without animating.
+func (c *camera) jump(v viewport) {
+	c.view = v
+	c.to = v
+	c.start = time.Time{}
+}
+
+// zoom zooms by factor around the point at, which is expressed
+// relative to the displayed viewport.
+func (c *camera) zoom(gtx C, at f32.Point, factor float32) {
+	p := c.view.offset.Add(f32.Pt(at.X*c.view.size.X, at.Y*c.view.size.Y))
+	v := c.to
+	v.zoom(f32.Pt((p.X-v.offset.X)/v.size.X, (p.Y-v.offset.Y)/v.size.Y), factor)
+	c.animate(gtx, v)
+}
+
+// pan immediately moves the displayed viewport by delta, expressed
+// relative to it, ending any running transition.
+func (c *camera) pan(delta f32.Point) {
+	v := c.view
+	v.pan(delta)
+	c.jump(v)
+}
+
+// follow recenters the camera on p, which is expressed in the larger
+// space, without changing the magnification.
+func (c *camera) follow(p f32.Point) {
+	d := p.Sub(c.to.center())
+	c.view.offset = c.view.offset.Add(d)
+	c.from.offset = c.from.offset.Add(d)
+	c.to.offset = c.to.offset.Add(d)
+}
+
+// subview zooms into the region other of the displayed viewport,
+// remembering the current viewport in the history.
+func (c *camera) subview(gtx C, other *viewport) {
+	c.push()
+	v := c.view
+	v.subview(other)
+	c.animate(gtx, v)
+}
+
+// back returns to the most recent viewport in the history.
+func (c *camera) back(gtx C) {
+	n := len(c.history)
+	if n == 0 {
+		return
+	}
+	v := c.history[n-1]
+	c.history = c.history[:n-1]
+	c.animate(gtx, v)
+}
+
+// restore returns to the history entry at index i, discarding every
+// entry after it.
+func (c *camera) restore(gtx C, i int) {
+	if i < 0 || i >= len(c.history) {
+		return
+	}
+	v := c.history[i]
+	c.history = c.history[:i]
+	c.animate(gtx, v)
+}
+
+// reset returns to fullView and clears the history.
+func (c *camera) reset(gtx C) {
+	c.history = c.history[:0]
+	c.animate(gtx, fullView)
+}
+
+// update advances any running transition to gtx.Now and returns the
+// viewport to display.
+func (c *camera) update(gtx C) *viewport {
+	if c.start.IsZero() {
+		return &c.view
+	}
+	t := float32(gtx.Now.Sub(c.start)) / float32(transitionDuration)
+	if t >= 1 {
+		c.jump(c.to)
+		return &c.view
+	}
+	// Ease out cubically so that the transition settles gently.
+	t = 1 - (1-t)*(1-t)*(1-t)
+	c.view = c.from.interpolate(c.to, t)
+	gtx.Execute(op.InvalidateCmd{})
+	return &c.view
+}
@@ -0,0 +1,234 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+const (
+	// activityWeeks is the number of weeks of activity shown, ending
+	// with the current week.
+	activityWeeks = 53
+	// maxActivityPages bounds the number of pages of commits fetched
+	// for the activity of a user.
+	maxActivityPages = 10
+)
+
+// activity counts the commits of a user per day.
+type activity struct {
+	start time.Time // the Sunday starting the first week, as a UTC date
+	today int       // the day of today
+	days  [activityWeeks * 7]int
+	// truncated is set if not all commits were counted.
+	truncated bool
+}
+
+// heatColors are the colors of the heat calendar, from no commits to
+// the most commits on a day.
+var heatColors = [...]color.NRGBA{
+	rgb(0xebedf0),
+	rgb(0x9be9a8),
+	rgb(0x40c463),
+	rgb(0x30a14e),
+	rgb(0x216e39),
+}
+
+func newActivity(now time.Time) *activity {
+	today := date(now)
+	wd := int(today.Weekday())
+	start := today.AddDate(0, 0, -wd-(activityWeeks-1)*7)
+	return &activity{start: start, today: (activityWeeks-1)*7 + wd}
+}
+
+// date returns the calendar date of t, in the location of t, as a UTC
+// time so that days are always 24 hours apart.
+func date(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// add counts a commit made at t, if t is within the period covered.
+func (a *activity) add(t time.Time) {
+	day := int(date(t).Sub(a.start).Hours() / 24)
+	if day < 0 || day > a.today {
+		return
+	}
+	a.days[day]++
+}
+
+// week returns the number of commits in week w.
+func (a *activity) week(w int) int {
+	n := 0
+	for _, c := range a.days[w*7 : w*7+7] {
+		n += c
+	}
+	return n
+}
+
+// total returns the number of commits counted.
+func (a *activity) total() int {
+	n := 0
+	for _, c := range a.days {
+		n += c
+	}
+	return n
+}
+
+// level returns the index into heatColors for a day with n commits,
+// when the busiest day has max commits.
+func level(n, max int) int {
+	if n == 0 || max == 0 {
+		return 0
+	}
+	l := 1 + (n*(len(heatColors)-1)-1)/max
+	return min(l, len(heatColors)-1)
+}
+
+func (up *userPage) layoutActivity(gtx layout.Context) layout.Dimensions {
+	if up.activity == nil && !up.activityLoading && up.activityErr == nil && up.fetchActivity != nil {
+		up.activityLoading = true
+		up.fetchActivity(up)
+	}
+	a := up.activity
+	switch {
+	case a != nil:
+	case up.activityErr != nil:
+		return layout.Center.Layout(gtx, func(gtx C) D {
+			return layoutStatus(gtx, "Couldn't load activity.", &up.activityRetry)
+		})
+	default:
+		return layout.Center.Layout(gtx, func(gtx C) D {
+			return layoutStatus(gtx, "Loading activity…", nil)
+		})
+	}
+	summary := fmt.Sprintf("%s in the last year", plural(a.total(), "commit"))
+	if a.truncated {
+		summary = fmt.Sprintf("At least %s in the last year", plural(a.total(), "commit"))
+	}
+	in := layout.UniformInset(unit.Dp(16))
+	return in.Layout(gtx, func(gtx C) D {
+		gtx.Constraints.Min.X = gtx.Constraints.Max.X
+		return column().Layout(gtx,
+			layout.Rigid(material.Body1(theme, summary).Layout),
+			layout.Rigid(layout.Spacer{Height: unit.Dp(16)}.Layout),
+			layout.Rigid(chartTitle("COMMITS PER WEEK")),
+			layout.Rigid(a.layoutWeeks),
+			layout.Rigid(layout.Spacer{Height: unit.Dp(24)}.Layout),
+			layout.Rigid(chartTitle("CONTRIBUTIONS")),
+			layout.Rigid(a.layoutCalendar),
+		)
+	})
+}
+
+func chartTitle(txt string) layout.Widget {
+	return func(gtx C) D {
+		lbl := material.Caption(theme, txt)
+		lbl.Color = rgb(0x888888)
+		return layout.Inset{Bottom: unit.Dp(8)}.Layout(gtx, lbl.Layout)
+	}
+}
+
+// layoutWeeks draws a bar chart of the commits per week.
+func (a *activity) layoutWeeks(gtx layout.Context) layout.Dimensions {
+	width := gtx.Constraints.Max.X
+	height := gtx.Dp(unit.Dp(120))
+	maxWeek := 0
+	for w := range activityWeeks {
+		maxWeek = max(maxWeek, a.week(w))
+	}
+	size := image.Pt(width, height)
+	// Baseline.
+	paint.FillShape(gtx.Ops, heatColors[0], clip.Rect{Min: image.Pt(0, height-gtx.Dp(1)), Max: size}.Op())
+	if maxWeek == 0 {
+		return D{Size: size}
+	}
+	step := float32(width) / activityWeeks
+	gap := min(float32(gtx.Dp(unit.Dp(2))), step/4)
+	var p clip.Path
+	p.Begin(gtx.Ops)
+	for w := range activityWeeks {
+		n := a.week(w)
+		if n == 0 {
+			continue
+		}
+		h := float32(height) * float32(n) / float32(maxWeek)
+		x0 := float32(w)*step + gap/2
+		x1 := x0 + step - gap
+		p.MoveTo(f32.Pt(x0, float32(height)))
+		p.LineTo(f32.Pt(x0, float32(height)-h))
+		p.LineTo(f32.Pt(x1, float32(height)-h))
+		p.LineTo(f32.Pt(x1, float32(height)))
+		p.Close()
+	}
+	paint.FillShape(gtx.Ops, heatColors[2], clip.Outline{Path: p.End()}.Op())
+	lbl := material.Caption(theme, fmt.Sprintf("%d", maxWeek))
+	lbl.Color = rgb(0x888888)
+	lbl.Layout(gtx)
+	return D{Size: size}
+}
+
+// layoutCalendar draws a heat calendar of the commits per day, with a
+// column per week and the months labeled above.
+func (a *activity) layoutCalendar(gtx layout.Context) layout.Dimensions {
+	gap := gtx.Dp(unit.Dp(2))
+	cell := min(gtx.Dp(unit.Dp(12)), gtx.Constraints.Max.X/activityWeeks-gap)
+	if cell <= 0 {
+		return D{}
+	}
+	step := cell + gap
+	maxDay := 0
+	for _, n := range a.days {
+		maxDay = max(maxDay, n)
+	}
+
+	// Label the months above their first full week, leaving out the
+	// partial month at the start.
+	lgtx := gtx
+	lgtx.Constraints.Min = image.Point{}
+	labelHeight := 0
+	for w := 1; w < activityWeeks; w++ {
+		day := a.start.AddDate(0, 0, w*7)
+		if day.Day() > 7 {
+			continue
+		}
+		st := op.Offset(image.Pt(w*step, 0)).Push(gtx.Ops)
+		lbl := material.Caption(theme, day.Format("Jan"))
+		lbl.Color = rgb(0x888888)
+		dims := lbl.Layout(lgtx)
+		labelHeight = max(labelHeight, dims.Size.Y)
+		st.Pop()
+	}
+
+	// Draw a path per color, so that the calendar takes few operations.
+	top := labelHeight + gap
+	for l, col := range heatColors {
+		var p clip.Path
+		p.Begin(gtx.Ops)
+		for day := 0; day <= a.today; day++ {
+			if level(a.days[day], maxDay) != l {
+				continue
+			}
+			x := float32(day / 7 * step)
+			y := float32(top + day%7*step)
+			p.MoveTo(f32.Pt(x, y))
+			p.LineTo(f32.Pt(x+float32(cell), y))
+			p.LineTo(f32.Pt(x+float32(cell), y+float32(cell)))
+			p.LineTo(f32.Pt(x, y+float32(cell)))
+			p.Close()
+		}
+		paint.FillShape(gtx.Ops, col, clip.Outline{Path: p.End()}.Op())
+	}
+	return D{Size: image.Pt(activityWeeks*step-gap, top+7*step-gap)}
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// credentials holds the token that authenticates API requests. The
+// token may change while requests are in flight, when the user signs
+// in or out.
+type credentials struct {
+	mu    sync.Mutex
+	token string
+}
+
+func (c *credentials) get() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *credentials) set(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// authTransport is an http.RoundTripper that adds the current token of
+// creds to requests, using the given authorization scheme.
+type authTransport struct {
+	creds  *credentials
+	scheme string
+	// host, if set, is the only host the token is sent to, so that it
+	// doesn't leak to other hosts such as those serving avatars.
+	host      string
+	transport http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	token := t.creds.get()
+	if token == "" || (t.host != "" && req.URL.Host != t.host) {
+		return transport.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", t.scheme+" "+token)
+	return transport.RoundTrip(req)
+}
+
+// tokenEnv returns the environment variable holding the token for the
+// given kind of forge.
+func tokenEnv(kind string) string {
+	if kind == "github" {
+		return "GITHUB_TOKEN"
+	}
+	return "GITEA_TOKEN"
+}
+
+// tokensFile returns the path of the file that stores the tokens the
+// user chose to remember, by API URL.
+func tokensFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gio-gophers", "tokens.json"), nil
+}
+
+func readTokens() (map[string]string, error) {
+	path, err := tokensFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[string]string{}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return tokens, nil
+}
+
+// loadToken returns the token for the API at apiURL from the
+// environment variable env or, failing that, the tokens file.
+func loadToken(env, apiURL string) (string, error) {
+	if token := os.Getenv(env); token != "" {
+		return token, nil
+	}
+	tokens, err := readTokens()
+	if err != nil {
+		return "", err
+	}
+	return tokens[apiURL], nil
+}
+
+// saveToken remembers token for the API at apiURL in the tokens file,
+// or forgets it if token is empty. The file is readable by the user
+// only.
+func saveToken(apiURL, token string) error {
+	tokens, err := readTokens()
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		if _, ok := tokens[apiURL]; !ok {
+			return nil
+		}
+		delete(tokens, apiURL)
+	} else {
+		tokens[apiURL] = token
+	}
+	path, err := tokensFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tokens, "", "\t")
+	if err != nil {
+		return err
+	}
+	// writeFileAtomic creates files readable by the user only.
+	return writeFileAtomic(path, data)
+}
+
+// loginEvent reports the progress of a device flow login.
+type loginEvent struct {
+	// device is set when the user is to enter a code at a URL.
+	device *oauth2.DeviceAuthResponse
+	token  string
+	err    error
+}
+
+// deviceLogin signs in to GitHub with the OAuth device flow: the user
+// enters a code shown by the app on a GitHub web page, while the app
+// waits for the resulting token. Progress is sent to a.loginEvents.
+func (a *App) deviceLogin(ctx context.Context) {
+	cfg := &oauth2.Config{
+		ClientID: a.oauthClientID,
+		Endpoint: endpoints.GitHub,
+		Scopes:   []string{"read:user"},
+	}
+	send := func(e loginEvent) bool {
+		select {
+		case a.loginEvents <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	da, err := cfg.DeviceAuth(ctx)
+	if err != nil {
+		send(loginEvent{err: fmt.Errorf("sign in failed: %w", err)})
+		return
+	}
+	if !send(loginEvent{device: da}) {
+		return
+	}
+	tok, err := cfg.DeviceAccessToken(ctx, da)
+	if err != nil {
+		if ctx.Err() == nil {
+			send(loginEvent{err: fmt.Errorf("sign in failed: %w", err)})
+		}
+		return
+	}
+	send(loginEvent{token: tok.AccessToken})
+}
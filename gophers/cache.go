@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errNotCached is returned in offline mode for requests that cannot be
+// answered from the cache.
+var errNotCached = errors.New("not available offline")
+
+// httpCache is an http.RoundTripper that stores successful GET
+// responses on disk. Cached responses are revalidated with conditional
+// requests, which GitHub does not count against the rate limit. In
+// offline mode, responses come from the cache alone.
+//
+// Responses are keyed by URL and credentials, so that responses to
+// authenticated requests are not returned to other users, or after
+// signing out.
+type httpCache struct {
+	dir       string
+	offline   bool
+	transport http.RoundTripper
+}
+
+// cachedResponse is the on-disk form of a response.
+type cachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func newHTTPCache(dir string, offline bool, transport http.RoundTripper) (*httpCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("cache: %v", err)
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &httpCache{dir: dir, offline: offline, transport: transport}, nil
+}
+
+func (c *httpCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		if c.offline {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, errNotCached)
+		}
+		return c.transport.RoundTrip(req)
+	}
+	// The key is hashed, so the credentials are not written to disk.
+	path := c.path(req.URL.String() + "\n" + req.Header.Get("Authorization"))
+	cached, err := c.load(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if c.offline {
+		if cached == nil {
+			return nil, fmt.Errorf("GET %s: %w", req.URL, errNotCached)
+		}
+		return cached.response(req), nil
+	}
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if etag := cached.Header.Get("Etag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if mod := cached.Header.Get("Last-Modified"); mod != "" {
+			req.Header.Set("If-Modified-Since", mod)
+		}
+	}
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		resp.Body.Close()
+		// Keep the fresh rate limit headers of the revalidation.
+		for k, v := range resp.Header {
+			if strings.HasPrefix(k, "X-Ratelimit-") {
+				cached.Header[k] = v
+			}
+		}
+		return cached.response(req), nil
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		c.store(path, &cachedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		})
+	}
+	return resp, nil
+}
+
+// path returns the file name for the cache entry of key.
+func (c *httpCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *httpCache) load(path string) (*cachedResponse, error) {
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, err
+	}
+	cr := new(cachedResponse)
+	if err := json.Unmarshal(data, cr); err != nil {
+		// Treat a corrupt entry as missing.
+		return nil, nil
+	}
+	return cr, nil
+}
+
+// store writes a cache entry. Failures are reported but otherwise
+// ignored, since the cache is only an optimization.
+func (c *httpCache) store(path string, cr *cachedResponse) {
+	data, err := json.Marshal(cr)
+	if err == nil {
+		err = writeFileAtomic(path+".json", data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cache: %v\n", err)
+	}
+}
+
+func (cr *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cr.StatusCode, http.StatusText(cr.StatusCode)),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(cr.Body)),
+		ContentLength: int64(len(cr.Body)),
+		Request:       req,
+	}
+}
+
+// imageCache stores decoded avatars on disk, so that they need not be
+// downloaded again.
+type imageCache struct {
+	dir string
+}
+
+func newImageCache(dir string) (*imageCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("cache: %v", err)
+	}
+	return &imageCache{dir: dir}, nil
+}
+
+func (c *imageCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".png")
+}
+
+// load returns the image cached for url.
+func (c *imageCache) load(url string) (image.Image, error) {
+	f, err := os.Open(c.path(url))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// store caches img for url.
+func (c *imageCache) store(url string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path(url), buf.Bytes())
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to
+// name, so that concurrent readers never see a partial file.
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
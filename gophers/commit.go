@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gioui.org/font"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// commitPage shows the details of a commit, including the files it
+// changed.
+type commitPage struct {
+	user *user
+	sha  string
+	// commit is the commit as listed, until the full commit with its
+	// files is loaded.
+	commit      *commit
+	fetchCommit func(sha string)
+	loaded      bool
+	loading     bool
+	err         error
+	retry       widget.Clickable
+	back        widget.Clickable
+	list        widget.List
+}
+
+// statBlocks is the number of blocks in a stat bar.
+const statBlocks = 5
+
+var (
+	additionColor = rgb(0x2cbe4e)
+	deletionColor = rgb(0xcb2431)
+	neutralColor  = rgb(0xd1d5da)
+)
+
+func newCommitPage(u *user, c *commit, fetch func(sha string)) *commitPage {
+	return &commitPage{
+		user:        u,
+		sha:         c.sha,
+		commit:      c,
+		fetchCommit: fetch,
+		list:        widget.List{List: layout.List{Axis: layout.Vertical}},
+	}
+}
+
+func (cp *commitPage) Layout(gtx layout.Context, backIcon *widget.Icon) layout.Dimensions {
+	if !cp.loaded && !cp.loading && cp.err == nil && cp.fetchCommit != nil {
+		cp.loading = true
+		cp.fetchCommit(cp.sha)
+	}
+	c := cp.commit
+	// The list holds the summary and the changed files, followed by a
+	// placeholder or error while the files load.
+	n := 1 + len(c.files)
+	if !cp.loaded {
+		n++
+	}
+	return column().Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return layout.Stack{}.Layout(gtx,
+				layout.Expanded(fill{rgb(0xf2f2f2)}.Layout),
+				layout.Stacked(func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					return centerRowOpts().Layout(gtx,
+						layout.Rigid(func(gtx C) D {
+							btn := material.IconButton(theme, &cp.back, backIcon, "Back")
+							btn.Background = color.NRGBA{}
+							btn.Color = theme.Fg
+							btn.Inset = layout.UniformInset(unit.Dp(8))
+							return btn.Layout(gtx)
+						}),
+						layout.Rigid(func(gtx C) D {
+							lbl := material.Caption(theme, fmt.Sprintf("COMMIT %.7s", cp.sha))
+							lbl.Color = rgb(0x888888)
+							return lbl.Layout(gtx)
+						}),
+					)
+				}),
+			)
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return material.List(theme, &cp.list).Layout(gtx, n, func(gtx C, i int) D {
+				switch {
+				case i == 0:
+					return cp.layoutSummary(gtx)
+				case i-1 < len(c.files):
+					return layoutFile(gtx, &c.files[i-1])
+				case cp.err != nil:
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					return layoutStatus(gtx, "Couldn't load the changed files.", &cp.retry)
+				default:
+					return layoutPlaceholder(gtx)
+				}
+			})
+		}),
+	)
+}
+
+// layoutSummary lays out the author, date, hash and message of the
+// commit, and the size of the change once it is known.
+func (cp *commitPage) layoutSummary(gtx layout.Context) layout.Dimensions {
+	c := cp.commit
+	author := c.authorName
+	if login := c.authorLogin; login != "" && login != author {
+		author = fmt.Sprintf("%s (%s)", author, login)
+	}
+	in := layout.UniformInset(unit.Dp(16))
+	return in.Layout(gtx, func(gtx C) D {
+		gtx.Constraints.Min.X = gtx.Constraints.Max.X
+		return column().Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				return centerRowOpts().Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						sz := gtx.Dp(unit.Dp(40))
+						cc := clipCircle{}
+						return cc.Layout(gtx, func(gtx C) D {
+							gtx.Constraints = layout.Exact(gtx.Constraints.Constrain(image.Point{X: sz, Y: sz}))
+							return cp.user.layoutAvatar(gtx)
+						})
+					}),
+					layout.Flexed(1, func(gtx C) D {
+						return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+							return column().Layout(gtx,
+								layout.Rigid(material.Body1(theme, author).Layout),
+								layout.Rigid(func(gtx C) D {
+									lbl := material.Caption(theme, c.date.Format("Jan 2, 2006 15:04 MST"))
+									lbl.Color = rgb(0x888888)
+									return lbl.Layout(gtx)
+								}),
+							)
+						})
+					}),
+				)
+			}),
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Caption(theme, cp.sha)
+				lbl.Font.Typeface = "Go Mono"
+				lbl.Color = rgb(0x888888)
+				return layout.Inset{Top: unit.Dp(12)}.Layout(gtx, lbl.Layout)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return layout.Inset{Top: unit.Dp(12)}.Layout(gtx,
+					material.Body2(theme, c.message).Layout)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if !cp.loaded {
+					return D{}
+				}
+				summary := fmt.Sprintf("%s changed, %d additions, %d deletions",
+					plural(len(c.files), "file"), c.additions, c.deletions)
+				return layout.Inset{Top: unit.Dp(16)}.Layout(gtx, func(gtx C) D {
+					return centerRowOpts().Layout(gtx,
+						layout.Rigid(statBar{c.additions, c.deletions}.Layout),
+						layout.Rigid(func(gtx C) D {
+							return layout.Inset{Left: unit.Dp(8)}.Layout(gtx,
+								material.Caption(theme, summary).Layout)
+						}),
+					)
+				})
+			}),
+		)
+	})
+}
+
+// layoutFile lays out a changed file with its additions and deletions.
+func layoutFile(gtx layout.Context, f *changedFile) layout.Dimensions {
+	name := f.name
+	if prev := f.previousName; prev != "" {
+		name = prev + " → " + name
+	}
+	in := layout.Inset{Top: unit.Dp(6), Right: unit.Dp(16), Bottom: unit.Dp(6), Left: unit.Dp(16)}
+	return in.Layout(gtx, func(gtx C) D {
+		gtx.Constraints.Min.X = gtx.Constraints.Max.X
+		return centerRowOpts().Layout(gtx,
+			layout.Flexed(1, func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Constraints.Max.X
+				lbl := material.Caption(theme, name)
+				lbl.Font.Typeface = "Go Mono"
+				lbl.MaxLines = 1
+				if f.status == "removed" {
+					lbl.Color = rgb(0x888888)
+				}
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if !f.counted {
+					return D{}
+				}
+				return layout.Inset{Left: unit.Dp(8), Right: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+					return baseline().Layout(gtx,
+						layout.Rigid(changeLabel(fmt.Sprintf("+%d", f.additions), additionColor)),
+						layout.Rigid(layout.Spacer{Width: unit.Dp(4)}.Layout),
+						layout.Rigid(changeLabel(fmt.Sprintf("−%d", f.deletions), deletionColor)),
+					)
+				})
+			}),
+			layout.Rigid(func(gtx C) D {
+				if !f.counted {
+					return D{}
+				}
+				return statBar{f.additions, f.deletions}.Layout(gtx)
+			}),
+		)
+	})
+}
+
+func changeLabel(txt string, col color.NRGBA) layout.Widget {
+	lbl := material.Caption(theme, txt)
+	lbl.Color = col
+	lbl.Font.Weight = font.Bold
+	return lbl.Layout
+}
+
+// statBar shows the proportion of additions and deletions in a change
+// as a row of colored blocks.
+type statBar struct {
+	additions, deletions int
+}
+
+// blocks returns the number of blocks to fill for additions and
+// deletions. Changes smaller than the bar fill one block per line,
+// larger changes fill the whole bar.
+func (s statBar) blocks() (add, del int) {
+	total := s.additions + s.deletions
+	if total <= statBlocks {
+		return s.additions, s.deletions
+	}
+	add = (s.additions*statBlocks + total/2) / total
+	// Show at least one block for each kind of change.
+	if s.additions > 0 {
+		add = max(add, 1)
+	}
+	if s.deletions > 0 {
+		add = min(add, statBlocks-1)
+	}
+	return add, statBlocks - add
+}
+
+func (s statBar) Layout(gtx layout.Context) layout.Dimensions {
+	sz := gtx.Dp(unit.Dp(8))
+	gap := gtx.Dp(unit.Dp(1))
+	add, del := s.blocks()
+	for i := range statBlocks {
+		col := neutralColor
+		switch {
+		case i < add:
+			col = additionColor
+		case i < add+del:
+			col = deletionColor
+		}
+		x := i * (sz + gap)
+		r := image.Rect(x, 0, x+sz, sz)
+		paint.FillShape(gtx.Ops, col, clip.Rect(r).Op())
+	}
+	return D{Size: image.Pt(statBlocks*(sz+gap)-gap, sz)}
+}
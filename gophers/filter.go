@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// userOrder is an order in which to list users.
+type userOrder string
+
+const (
+	byContributions userOrder = "contributions"
+	byName          userOrder = "name"
+	byCompany       userOrder = "company"
+)
+
+// userFilter selects and orders users for display.
+type userFilter struct {
+	query string
+	order userOrder
+}
+
+// userKeys are the lower case forms of a user's fields, computed once
+// so that filtering and sorting many users doesn't allocate.
+type userKeys struct {
+	login, name, company string
+}
+
+// keys returns the search and sort keys of u.
+func (u *user) keys() *userKeys {
+	if u.lower == nil {
+		u.lower = &userKeys{
+			login:   strings.ToLower(u.login),
+			name:    strings.ToLower(u.name),
+			company: strings.ToLower(u.company),
+		}
+	}
+	return u.lower
+}
+
+// matches reports whether u matches the query, which must be in lower
+// case. The query matches if it occurs in the user's login, name or
+// company, ignoring case.
+func (f userFilter) matches(u *user) bool {
+	if f.query == "" {
+		return true
+	}
+	k := u.keys()
+	return strings.Contains(k.login, f.query) ||
+		strings.Contains(k.name, f.query) ||
+		strings.Contains(k.company, f.query)
+}
+
+// compare orders users according to f. Ties are broken by login, so
+// that the order is stable as users stream in.
+func (f userFilter) compare(a, b *user) int {
+	ka, kb := a.keys(), b.keys()
+	var c int
+	switch f.order {
+	case byName:
+		c = cmp.Compare(ka.name, kb.name)
+	case byCompany:
+		// Users without a company sort last.
+		if (ka.company == "") != (kb.company == "") {
+			if ka.company == "" {
+				return 1
+			}
+			return -1
+		}
+		c = cmp.Compare(ka.company, kb.company)
+	default:
+		c = cmp.Compare(b.contributions, a.contributions)
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(ka.login, kb.login)
+}
+
+// apply returns the indices into users of the users selected by f, in
+// order. The result reuses the storage of dst.
+func (f userFilter) apply(dst []int, users []*user) []int {
+	dst = dst[:0]
+	for i, u := range users {
+		if f.matches(u) {
+			dst = append(dst, i)
+		}
+	}
+	slices.SortFunc(dst, func(i, j int) int {
+		return f.compare(users[i], users[j])
+	})
+	return dst
+}
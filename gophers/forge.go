@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"time"
+)
+
+// forge is a code hosting service, such as GitHub or Gitea.
+type forge interface {
+	// Contributors returns a page of the contributors to r, most
+	// active first, and the number of the next page, or 0 if this is
+	// the last page.
+	Contributors(ctx context.Context, r repository, page int) ([]contributor, int, error)
+	// User returns the profile of the user with the given login.
+	User(ctx context.Context, login string) (profile, error)
+	// Commits returns a page of the commits to r that match q, newest
+	// first, and the number of the next page, or 0 if this is the last
+	// page. The commits don't include their changed files. Forges that
+	// give up searching before the end of the history return the
+	// commits found with errTruncated.
+	Commits(ctx context.Context, r repository, q commitQuery) ([]*commit, int, error)
+	// Commit returns a commit to r along with its changed files.
+	Commit(ctx context.Context, r repository, sha string) (*commit, error)
+	// Avatar returns the avatar image at url.
+	Avatar(ctx context.Context, url string) (image.Image, error)
+}
+
+// errTruncated reports that a forge stopped searching for commits
+// before the end of the history.
+var errTruncated = errors.New("only the recent history was searched")
+
+// contributor is a user who contributed to a repository.
+type contributor struct {
+	login         string
+	avatarURL     string
+	contributions int
+}
+
+// profile is the public profile of a user.
+type profile struct {
+	name    string
+	company string
+}
+
+// commitQuery selects a page of commits.
+type commitQuery struct {
+	author  string    // login of the author, or empty for all commits
+	since   time.Time // earliest commit date, or zero
+	page    int
+	perPage int
+}
+
+// commit is a commit to a repository.
+type commit struct {
+	sha         string
+	message     string
+	authorName  string
+	authorLogin string // empty if the author has no account
+	date        time.Time
+
+	// The size of the change and the files changed are only known for
+	// commits returned by forge.Commit.
+	additions, deletions int
+	files                []changedFile
+}
+
+// changedFile describes the change to a file in a commit.
+type changedFile struct {
+	name         string
+	previousName string // the name before a rename, if any
+	status       string // such as "added", "modified" or "removed"
+	// counted reports whether additions and deletions are known.
+	counted   bool
+	additions int
+	deletions int
+}
+
+// httpAvatar downloads and decodes the image at url.
+func httpAvatar(ctx context.Context, client *http.Client, url string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("avatar: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("avatar: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("avatar: GET %s: %s", url, resp.Status)
+	}
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("avatar: image decode failed: %v", err)
+	}
+	return img, nil
+}
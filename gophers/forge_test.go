@@ -0,0 +1,326 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// fakeForge is an in-memory forge for tests.
+type fakeForge struct {
+	contributors []contributor
+	profiles     map[string]profile
+	commits      []*commit // newest first, with their files
+}
+
+// newFakeForge returns a forge with a few users and their commits.
+func newFakeForge() *fakeForge {
+	f := &fakeForge{
+		profiles: map[string]profile{
+			"alice": {name: "Alice", company: "Example"},
+			"bob":   {name: "Bob"},
+			"carol": {}, // no name, so not shown
+		},
+	}
+	date := time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+	for i := range 40 {
+		login := "alice"
+		if i%4 == 3 {
+			login = "bob"
+		}
+		f.commits = append(f.commits, &commit{
+			sha:         fmt.Sprintf("%040x", i),
+			message:     fmt.Sprintf("Change %d", i),
+			authorName:  f.profiles[login].name,
+			authorLogin: login,
+			date:        date.AddDate(0, 0, -i),
+			additions:   i,
+			deletions:   1,
+			files: []changedFile{
+				{name: "main.go", status: "modified", counted: true, additions: i, deletions: 1},
+			},
+		})
+	}
+	f.contributors = []contributor{
+		{login: "alice", avatarURL: "fake:alice", contributions: 30},
+		{login: "bob", avatarURL: "fake:bob", contributions: 10},
+		{login: "carol", avatarURL: "fake:carol", contributions: 1},
+	}
+	return f
+}
+
+// page returns the items of page of n items, and the next page.
+func page(n, page, perPage int) (start, end, next int) {
+	start = min((page-1)*perPage, n)
+	end = min(start+perPage, n)
+	if end < n {
+		next = page + 1
+	}
+	return start, end, next
+}
+
+func (f *fakeForge) Contributors(ctx context.Context, r repository, p int) ([]contributor, int, error) {
+	start, end, next := page(len(f.contributors), p, perPage)
+	return f.contributors[start:end], next, nil
+}
+
+func (f *fakeForge) User(ctx context.Context, login string) (profile, error) {
+	p, ok := f.profiles[login]
+	if !ok {
+		return profile{}, fmt.Errorf("fake: no user %q", login)
+	}
+	return p, nil
+}
+
+func (f *fakeForge) Commits(ctx context.Context, r repository, q commitQuery) ([]*commit, int, error) {
+	var matches []*commit
+	for _, c := range f.commits {
+		if q.author != "" && c.authorLogin != q.author || c.date.Before(q.since) {
+			continue
+		}
+		// Listings don't include the changed files.
+		listed := *c
+		listed.additions, listed.deletions, listed.files = 0, 0, nil
+		matches = append(matches, &listed)
+	}
+	start, end, next := page(len(matches), q.page, q.perPage)
+	return matches[start:end], next, nil
+}
+
+func (f *fakeForge) Commit(ctx context.Context, r repository, sha string) (*commit, error) {
+	for _, c := range f.commits {
+		if c.sha == sha {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("fake: no commit %s", sha)
+}
+
+func (f *fakeForge) Avatar(ctx context.Context, url string) (image.Image, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+	return img, nil
+}
+
+func TestFakeForge(t *testing.T) {
+	a := &App{
+		forge:         newFakeForge(),
+		updateUsers:   make(chan usersResult),
+		commitsResult: make(chan commitsResult, 1),
+		commitDetail:  make(chan commitDetail, 1),
+		activity:      make(chan activityResult, 1),
+		concurrency:   2,
+	}
+	ctx := context.Background()
+	r := repository{owner: "example", name: "repo"}
+	u := newUI(func(up *userPage, page int) {
+		a.fetchCommits(ctx, r, up, page)
+	})
+	u.fetchCommit = func(sha string) { a.fetchCommit(ctx, r, sha) }
+	u.fetchActivity = func(up *userPage) {
+		go a.fetchActivity(ctx, r, up, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC))
+	}
+	var ops op.Ops
+	frame := func() {
+		gtx := layout.Context{
+			Ops:         &ops,
+			Constraints: layout.Exact(image.Pt(400, 800)),
+		}
+		u.Layout(gtx)
+	}
+
+	go a.fetchContributors(ctx, r, 1)
+	for res := range a.updateUsers {
+		u.addUsers(res.users)
+		if res.done {
+			if res.err != nil {
+				t.Fatal(res.err)
+			}
+			break
+		}
+	}
+	frame()
+	var names []string
+	for _, i := range u.visible {
+		names = append(names, u.users[i].name)
+	}
+	if got, want := strings.Join(names, ","), "Alice,Bob"; got != want {
+		t.Fatalf("got users %q, want %q", got, want)
+	}
+
+	up := u.newUserPage(u.users[u.visible[1]])
+	u.selectedUser = up
+	frame()
+	res := <-a.commitsResult
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.page != up {
+		t.Errorf("commits for page %p, want %p", res.page, up)
+	}
+	up.addCommits(res.commits)
+	up.loading = false
+	if got, want := len(up.commits), 10; got != want {
+		t.Errorf("got %d commits by bob, want %d", got, want)
+	}
+	frame()
+
+	up.detail = newCommitPage(up.user, up.commits[0], up.fetchCommit)
+	frame()
+	detail := <-a.commitDetail
+	if detail.err != nil {
+		t.Fatal(detail.err)
+	}
+	if got, want := len(detail.commit.files), 1; got != want {
+		t.Errorf("got %d changed files, want %d", got, want)
+	}
+	up.detail.commit, up.detail.loaded = detail.commit, true
+	frame()
+
+	u.back()
+	up.tab.Value = string(activityTab)
+	frame()
+	act := <-a.activity
+	if act.err != nil {
+		t.Fatal(act.err)
+	}
+	if got, want := act.activity.total(), 10; got != want {
+		t.Errorf("got activity of %d commits, want %d", got, want)
+	}
+	up.activity = act.activity
+	frame()
+}
+
+func TestGitea(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	reply := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Error(err)
+		}
+	}
+	commit := func(sha, login string) map[string]any {
+		return map[string]any{
+			"sha": sha,
+			"commit": map[string]any{
+				"message": "Change " + sha,
+				"author":  map[string]any{"name": login, "date": "2024-03-04T10:00:00Z"},
+			},
+			"author": map[string]any{"login": login, "avatar_url": srv.URL + "/avatars/" + login},
+		}
+	}
+	mux.HandleFunc("/api/v1/repos/example/repo/commits", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		reply(w, []any{commit("1", "bob"), commit("2", "alice"), commit("3", "alice")})
+	})
+	// Every page of the history of example/big is full, by bob.
+	mux.HandleFunc("/api/v1/repos/example/big/commits", func(w http.ResponseWriter, r *http.Request) {
+		page := make([]any, giteaPageSize)
+		for i := range page {
+			page[i] = commit(r.URL.Query().Get("page")+"-"+strconv.Itoa(i), "bob")
+		}
+		reply(w, page)
+	})
+	mux.HandleFunc("/api/v1/repos/example/repo/git/commits/2", func(w http.ResponseWriter, r *http.Request) {
+		c := commit("2", "alice")
+		c["stats"] = map[string]any{"additions": 3, "deletions": 1}
+		c["files"] = []any{map[string]any{"filename": "main.go", "status": "modified"}}
+		reply(w, c)
+	})
+	mux.HandleFunc("/api/v1/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"login": "alice", "full_name": "Alice", "location": "Earth"})
+	})
+	mux.HandleFunc("/avatars/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(w, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Error(err)
+		}
+	})
+
+	f, err := newGitea(srv.URL+"/api/v1", &credentials{token: "secret"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	r := repository{owner: "example", name: "repo"}
+	cons, next, err := f.Contributors(ctx, r, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 0 {
+		t.Errorf("got next page %d, want 0", next)
+	}
+	var got []string
+	for _, c := range cons {
+		got = append(got, fmt.Sprintf("%s:%d", c.login, c.contributions))
+	}
+	if got, want := strings.Join(got, ","), "alice:2,bob:1"; got != want {
+		t.Errorf("got contributors %q, want %q", got, want)
+	}
+	p, err := f.User(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (profile{name: "Alice", company: "Earth"}); p != want {
+		t.Errorf("got profile %+v, want %+v", p, want)
+	}
+	commits, _, err := f.Commits(ctx, r, commitQuery{author: "alice", page: 1, perPage: perPage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(commits), 2; got != want {
+		t.Errorf("got %d commits by alice, want %d", got, want)
+	}
+	big := repository{owner: "example", name: "big"}
+	if _, next, err := f.Commits(ctx, big, commitQuery{author: "alice", page: 1}); err != nil || next != 2 {
+		t.Errorf("got next page %d, error %v, want page 2", next, err)
+	}
+	_, next, err = f.Commits(ctx, big, commitQuery{author: "alice", page: giteaHistoryPages})
+	if !errors.Is(err, errTruncated) || next != 0 {
+		t.Errorf("got next page %d, error %v at the last page scanned, want errTruncated", next, err)
+	}
+	c, err := f.Commit(ctx, r, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.additions != 3 || c.deletions != 1 || len(c.files) != 1 || c.files[0].name != "main.go" {
+		t.Errorf("got commit %+v, want 3 additions and 1 deletion in main.go", c)
+	}
+	if _, err := f.Avatar(ctx, cons[0].avatarURL); err != nil {
+		t.Error(err)
+	}
+	// The token is for the forge only, not for other avatar hosts.
+	avatars := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("avatar host got Authorization %q", auth)
+		}
+		if err := png.Encode(w, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(avatars.Close)
+	if _, err := f.Avatar(ctx, avatars.URL+"/alice.png"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// giteaPageSize is the number of commits requested per page from
+	// Gitea, which limits pages to 50 items by default.
+	giteaPageSize = 50
+	// giteaHistoryPages bounds the number of pages of history scanned
+	// for contributors, and for the commits by a user.
+	giteaHistoryPages = 20
+)
+
+// giteaForge is the forge for Gitea and Forgejo, such as
+// https://codeberg.org.
+//
+// Gitea has no API for the contributors of a repository, so they are
+// found by counting the authors in the recent commit history. Neither
+// can commits be listed by author, so commits by a user are picked out
+// of the full history, page by page.
+type giteaForge struct {
+	base   *url.URL
+	client *http.Client
+
+	mu sync.Mutex
+	// contributors caches the contributors to each repository, found
+	// when their first page is requested.
+	contributors map[repository][]contributor
+}
+
+// giteaCommit is the JSON form of a Gitea commit.
+type giteaCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+	Author *struct {
+		Login     string `json:"login"`
+		AvatarURL string `json:"avatar_url"`
+	} `json:"author"`
+	Stats *struct {
+		Additions int `json:"additions"`
+		Deletions int `json:"deletions"`
+	} `json:"stats"`
+	Files []struct {
+		Filename string `json:"filename"`
+		Status   string `json:"status"`
+	} `json:"files"`
+}
+
+// newGitea returns a forge for the Gitea API at apiURL, such as
+// https://codeberg.org/api/v1/, authenticated with the token of creds
+// if it is not empty. Requests are made through transport, or
+// http.DefaultTransport if it is nil.
+func newGitea(apiURL string, creds *credentials, transport http.RoundTripper) (*giteaForge, error) {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("gitea: invalid API URL %q", apiURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &giteaForge{
+		base:         u,
+		client:       &http.Client{Transport: &authTransport{creds: creds, scheme: "token", host: u.Host, transport: transport}},
+		contributors: make(map[repository][]contributor),
+	}, nil
+}
+
+// get fetches the JSON resource at path, relative to the API URL, and
+// decodes it into v.
+func (f *giteaForge) get(ctx context.Context, path string, query url.Values, v any) error {
+	u := f.base.JoinPath(path)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("gitea: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gitea: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gitea: GET %s: %s", u, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("gitea: GET %s: %v", u, err)
+	}
+	return nil
+}
+
+// history returns a page of the commits to r, with the next page.
+func (f *giteaForge) history(ctx context.Context, r repository, page int, since time.Time) ([]giteaCommit, int, error) {
+	q := url.Values{
+		"page":         {strconv.Itoa(page)},
+		"limit":        {strconv.Itoa(giteaPageSize)},
+		"stat":         {"false"},
+		"verification": {"false"},
+		"files":        {"false"},
+	}
+	if !since.IsZero() {
+		q.Set("since", since.Format(time.RFC3339))
+	}
+	var commits []giteaCommit
+	if err := f.get(ctx, "repos/"+r.owner+"/"+r.name+"/commits", q, &commits); err != nil {
+		return nil, 0, err
+	}
+	next := 0
+	if len(commits) == giteaPageSize {
+		next = page + 1
+	}
+	// Servers that ignore since return older commits as well.
+	if !since.IsZero() && len(commits) > 0 && commits[len(commits)-1].Commit.Author.Date.Before(since) {
+		next = 0
+	}
+	return commits, next, nil
+}
+
+func (f *giteaForge) Contributors(ctx context.Context, r repository, page int) ([]contributor, int, error) {
+	f.mu.Lock()
+	cons, ok := f.contributors[r]
+	f.mu.Unlock()
+	if !ok || page == 1 {
+		var err error
+		cons, err = f.findContributors(ctx, r)
+		if err != nil {
+			return nil, 0, err
+		}
+		f.mu.Lock()
+		f.contributors[r] = cons
+		f.mu.Unlock()
+	}
+	start := min((page-1)*perPage, len(cons))
+	end := min(start+perPage, len(cons))
+	next := 0
+	if end < len(cons) {
+		next = page + 1
+	}
+	return cons[start:end], next, nil
+}
+
+// findContributors counts the authors of the recent commits to r.
+func (f *giteaForge) findContributors(ctx context.Context, r repository) ([]contributor, error) {
+	index := make(map[string]int)
+	var cons []contributor
+	for page := 1; page != 0 && page <= giteaHistoryPages; {
+		commits, next, err := f.history(ctx, r, page, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range commits {
+			if c.Author == nil || c.Author.Login == "" {
+				continue
+			}
+			i, ok := index[c.Author.Login]
+			if !ok {
+				i = len(cons)
+				index[c.Author.Login] = i
+				cons = append(cons, contributor{login: c.Author.Login, avatarURL: c.Author.AvatarURL})
+			}
+			cons[i].contributions++
+		}
+		page = next
+	}
+	slices.SortStableFunc(cons, func(a, b contributor) int {
+		return cmp.Compare(b.contributions, a.contributions)
+	})
+	return cons, nil
+}
+
+func (f *giteaForge) User(ctx context.Context, login string) (profile, error) {
+	var u struct {
+		FullName string `json:"full_name"`
+		Location string `json:"location"`
+	}
+	if err := f.get(ctx, "users/"+login, nil, &u); err != nil {
+		return profile{}, err
+	}
+	// Gitea profiles have no company, so show the location instead.
+	return profile{name: u.FullName, company: u.Location}, nil
+}
+
+// Commits ignores q.perPage, since it must scan pages of the full
+// history for commits by q.author. Pages may therefore be empty. The
+// scan stops with errTruncated after giteaHistoryPages pages.
+func (f *giteaForge) Commits(ctx context.Context, r repository, q commitQuery) ([]*commit, int, error) {
+	history, next, err := f.history(ctx, r, q.page, q.since)
+	if err != nil {
+		return nil, 0, err
+	}
+	var commits []*commit
+	for i := range history {
+		c := &history[i]
+		if q.author != "" && (c.Author == nil || c.Author.Login != q.author) {
+			continue
+		}
+		if !q.since.IsZero() && c.Commit.Author.Date.Before(q.since) {
+			continue
+		}
+		commits = append(commits, c.commit())
+	}
+	if q.author != "" && next > giteaHistoryPages {
+		return commits, 0, errTruncated
+	}
+	return commits, next, nil
+}
+
+// Commit returns the changed files without their sizes, which Gitea
+// doesn't report.
+func (f *giteaForge) Commit(ctx context.Context, r repository, sha string) (*commit, error) {
+	q := url.Values{"stat": {"true"}, "files": {"true"}, "verification": {"false"}}
+	var gc giteaCommit
+	if err := f.get(ctx, "repos/"+r.owner+"/"+r.name+"/git/commits/"+sha, q, &gc); err != nil {
+		return nil, err
+	}
+	c := gc.commit()
+	if gc.Stats != nil {
+		c.additions = gc.Stats.Additions
+		c.deletions = gc.Stats.Deletions
+	}
+	c.files = make([]changedFile, len(gc.Files))
+	for i, file := range gc.Files {
+		c.files[i] = changedFile{name: file.Filename, status: file.Status}
+	}
+	return c, nil
+}
+
+func (f *giteaForge) Avatar(ctx context.Context, url string) (image.Image, error) {
+	return httpAvatar(ctx, f.client, url)
+}
+
+func (gc *giteaCommit) commit() *commit {
+	c := &commit{
+		sha:        gc.SHA,
+		message:    gc.Commit.Message,
+		authorName: gc.Commit.Author.Name,
+		date:       gc.Commit.Author.Date,
+	}
+	if gc.Author != nil {
+		c.authorLogin = gc.Author.Login
+	}
+	return c
+}
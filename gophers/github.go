@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v24/github"
+)
+
+// githubForge is the forge for GitHub and GitHub Enterprise.
+type githubForge struct {
+	client *github.Client
+	creds  *credentials
+	rate   rateGate
+}
+
+// newGitHub returns a forge for the GitHub API at apiURL,
+// authenticated with the token of creds if it is not empty. Requests
+// are made through transport, or http.DefaultTransport if it is nil.
+func newGitHub(apiURL string, creds *credentials, transport http.RoundTripper) (*githubForge, error) {
+	tc := &http.Client{Transport: &authTransport{creds: creds, scheme: "Bearer", transport: transport}}
+	client := github.NewClient(tc)
+	if apiURL != "" {
+		u, err := url.Parse(apiURL)
+		if err != nil {
+			return nil, fmt.Errorf("github: invalid API URL %q: %v", apiURL, err)
+		}
+		// The client resolves API paths relative to its base URL,
+		// which must therefore end in a slash.
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		client.BaseURL = u
+	}
+	return &githubForge{client: client, creds: creds}, nil
+}
+
+func (f *githubForge) Contributors(ctx context.Context, r repository, page int) ([]contributor, int, error) {
+	var (
+		cons []*github.Contributor
+		resp *github.Response
+	)
+	err := f.rate.do(f.creds.get(), func() (*github.Response, error) {
+		var err error
+		cons, resp, err = f.client.Repositories.ListContributors(ctx, r.owner, r.name, &github.ListContributorsOptions{
+			ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+		})
+		return resp, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	res := make([]contributor, len(cons))
+	for i, con := range cons {
+		res[i] = contributor{
+			login:         con.GetLogin(),
+			avatarURL:     con.GetAvatarURL(),
+			contributions: con.GetContributions(),
+		}
+	}
+	return res, resp.NextPage, nil
+}
+
+func (f *githubForge) User(ctx context.Context, login string) (profile, error) {
+	var u *github.User
+	err := f.rate.do(f.creds.get(), func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+		u, resp, err = f.client.Users.Get(ctx, login)
+		return resp, err
+	})
+	if err != nil {
+		return profile{}, err
+	}
+	return profile{name: u.GetName(), company: u.GetCompany()}, nil
+}
+
+func (f *githubForge) Commits(ctx context.Context, r repository, q commitQuery) ([]*commit, int, error) {
+	var (
+		repoCommits []*github.RepositoryCommit
+		resp        *github.Response
+	)
+	err := f.rate.do(f.creds.get(), func() (*github.Response, error) {
+		var err error
+		repoCommits, resp, err = f.client.Repositories.ListCommits(ctx, r.owner, r.name, &github.CommitsListOptions{
+			Author:      q.author,
+			Since:       q.since,
+			ListOptions: github.ListOptions{Page: q.page, PerPage: q.perPage},
+		})
+		return resp, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	var commits []*commit
+	for _, c := range repoCommits {
+		if c.GetCommit() != nil {
+			commits = append(commits, githubCommit(c))
+		}
+	}
+	return commits, resp.NextPage, nil
+}
+
+func (f *githubForge) Commit(ctx context.Context, r repository, sha string) (*commit, error) {
+	var c *github.RepositoryCommit
+	err := f.rate.do(f.creds.get(), func() (*github.Response, error) {
+		var (
+			resp *github.Response
+			err  error
+		)
+		c, resp, err = f.client.Repositories.GetCommit(ctx, r.owner, r.name, sha)
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := githubCommit(c)
+	res.additions = c.GetStats().GetAdditions()
+	res.deletions = c.GetStats().GetDeletions()
+	res.files = make([]changedFile, len(c.Files))
+	for i, file := range c.Files {
+		res.files[i] = changedFile{
+			name:         file.GetFilename(),
+			previousName: file.GetPreviousFilename(),
+			status:       file.GetStatus(),
+			counted:      true,
+			additions:    file.GetAdditions(),
+			deletions:    file.GetDeletions(),
+		}
+	}
+	return res, nil
+}
+
+// Avatar fetches avatars without authentication, since they are served
+// from a different host than the API.
+func (f *githubForge) Avatar(ctx context.Context, url string) (image.Image, error) {
+	return httpAvatar(ctx, http.DefaultClient, url)
+}
+
+func githubCommit(c *github.RepositoryCommit) *commit {
+	gc := c.GetCommit()
+	return &commit{
+		sha:         c.GetSHA(),
+		message:     gc.GetMessage(),
+		authorName:  gc.GetAuthor().GetName(),
+		authorLogin: c.GetAuthor().GetLogin(),
+		date:        gc.GetAuthor().GetDate(),
+	}
+}
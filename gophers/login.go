@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// loginDialog asks for a token, or signs in with the device flow.
+type loginDialog struct {
+	token    widget.Editor
+	remember widget.Bool
+	submit   widget.Clickable
+	cancel   widget.Clickable
+	start    widget.Clickable // starts the device flow
+	// device is the pending device flow login, if any.
+	device *oauth2.DeviceAuthResponse
+	code   widget.Selectable
+	url    widget.Selectable
+	// waiting is set while the device flow starts.
+	waiting bool
+	err     string
+
+	// scrim and card catch clicks outside and inside the dialog, so
+	// that they don't reach the content below.
+	scrim widget.Clickable
+	card  widget.Clickable
+}
+
+func newLoginDialog() *loginDialog {
+	d := new(loginDialog)
+	d.token.SingleLine = true
+	d.token.Submit = true
+	d.token.Mask = '•'
+	return d
+}
+
+// updateLogin handles the sign in button and the login dialog.
+func (u *UI) updateLogin(gtx layout.Context) {
+	if u.signIn.Clicked(gtx) {
+		if u.signedIn {
+			u.logout()
+		} else {
+			u.loginDialog = newLoginDialog()
+			gtx.Execute(key.FocusCmd{Tag: &u.loginDialog.token})
+		}
+	}
+	d := u.loginDialog
+	if d == nil {
+		return
+	}
+	submit := d.submit.Clicked(gtx)
+	for {
+		e, ok := d.token.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := e.(widget.SubmitEvent); ok {
+			submit = true
+		}
+	}
+	d.card.Clicked(gtx)
+	if d.cancel.Clicked(gtx) || d.scrim.Clicked(gtx) {
+		u.cancelLogin()
+		u.loginDialog = nil
+		return
+	}
+	if d.start.Clicked(gtx) && u.deviceLogin != nil {
+		d.err = ""
+		d.device = nil
+		d.waiting = true
+		u.deviceLogin()
+	}
+	if d.device != nil || d.err != "" {
+		d.waiting = false
+	}
+	if submit {
+		token := strings.TrimSpace(d.token.Text())
+		if token == "" {
+			d.err = "Enter a personal access token."
+			return
+		}
+		u.login(token, d.remember.Value)
+	}
+}
+
+// Layout lays out the dialog over a scrim that covers the content.
+func (d *loginDialog) Layout(gtx layout.Context, deviceFlow bool) layout.Dimensions {
+	return layout.Stack{Alignment: layout.Center}.Layout(gtx,
+		layout.Expanded(func(gtx C) D {
+			return d.scrim.Layout(gtx, func(gtx C) D {
+				return fill{argb(0x80000000)}.Layout(gtx)
+			})
+		}),
+		layout.Stacked(func(gtx C) D {
+			gtx.Constraints.Max.X = min(gtx.Constraints.Max.X, gtx.Dp(unit.Dp(360)))
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+				return d.card.Layout(gtx, func(gtx C) D {
+					return layout.Stack{}.Layout(gtx,
+						layout.Expanded(func(gtx C) D {
+							rr := gtx.Dp(unit.Dp(4))
+							paint.FillShape(gtx.Ops, rgb(0xffffff), clip.UniformRRect(image.Rectangle{Max: gtx.Constraints.Min}, rr).Op(gtx.Ops))
+							return D{Size: gtx.Constraints.Min}
+						}),
+						layout.Stacked(func(gtx C) D {
+							return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+								return d.layoutContent(gtx, deviceFlow)
+							})
+						}),
+					)
+				})
+			})
+		}),
+	)
+}
+
+func (d *loginDialog) layoutContent(gtx layout.Context, deviceFlow bool) layout.Dimensions {
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	children := []layout.FlexChild{
+		layout.Rigid(material.H6(theme, "Sign in").Layout),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+		layout.Rigid(material.Body2(theme, "Paste a personal access token.").Layout),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+				e := material.Editor(theme, &d.token, "Token")
+				e.TextSize = unit.Sp(14)
+				return e.Layout(gtx)
+			})
+		}),
+		layout.Rigid(material.CheckBox(theme, &d.remember, "Remember on this computer").Layout),
+	}
+	if deviceFlow {
+		children = append(children, layout.Rigid(d.layoutDeviceFlow))
+	}
+	children = append(children,
+		layout.Rigid(func(gtx C) D {
+			if d.err == "" {
+				return D{}
+			}
+			lbl := material.Caption(theme, d.err)
+			lbl.Color = rgb(0xc62828)
+			return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, lbl.Layout)
+		}),
+		layout.Rigid(func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+				return layout.Flex{Spacing: layout.SpaceStart}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						return textButton(gtx, &d.cancel, "CANCEL")
+					}),
+					layout.Rigid(func(gtx C) D {
+						return textButton(gtx, &d.submit, "SIGN IN")
+					}),
+				)
+			})
+		}),
+	)
+	return column().Layout(gtx, children...)
+}
+
+// layoutDeviceFlow lays out the button that starts the device flow,
+// or the code to enter once it has started.
+func (d *loginDialog) layoutDeviceFlow(gtx layout.Context) layout.Dimensions {
+	in := layout.Inset{Top: unit.Dp(16)}
+	return in.Layout(gtx, func(gtx C) D {
+		if d.device == nil {
+			txt := "Sign in with GitHub"
+			if d.waiting {
+				txt = "Contacting GitHub…"
+			}
+			btn := material.Button(theme, &d.start, txt)
+			if d.waiting {
+				btn.Background = rgb(0xbbbbbb)
+			}
+			return btn.Layout(gtx)
+		}
+		return column().Layout(gtx,
+			layout.Rigid(material.Body2(theme, "Open this page in a browser:").Layout),
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body2(theme, d.device.VerificationURI)
+				lbl.Color = theme.ContrastBg
+				lbl.State = &d.url
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+			layout.Rigid(material.Body2(theme, "and enter the code:").Layout),
+			layout.Rigid(func(gtx C) D {
+				lbl := material.H5(theme, d.device.UserCode)
+				lbl.Font.Typeface = "Go Mono"
+				lbl.Alignment = text.Middle
+				lbl.State = &d.code
+				gtx.Constraints.Min.X = gtx.Constraints.Max.X
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Caption(theme, "Waiting for you to approve the sign in…")
+				lbl.Color = rgb(0x888888)
+				return lbl.Layout(gtx)
+			}),
+		)
+	})
+}
@@ -2,30 +2,29 @@
 
 package main
 
-// A Gio program that displays Go contributors from GitHub. See https://gioui.org for more information.
+// A Gio program that displays Go contributors from GitHub or Gitea. See https://gioui.org for more information.
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
 	"log"
 	"net/http"
 	"os"
-	"slices"
-
-	"golang.org/x/oauth2"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"gioui.org/app"
-	"gioui.org/gesture"
 	"gioui.org/io/event"
 	"gioui.org/io/key"
 	"gioui.org/op"
 	"gioui.org/op/clip"
 	"gioui.org/unit"
 
-	"github.com/google/go-github/v24/github"
-
 	_ "image/jpeg"
 	_ "image/png"
 
@@ -37,32 +36,174 @@ type App struct {
 
 	ui *UI
 
-	updateUsers   chan []*user
-	commitsResult chan []*github.Commit
+	forge   forge
+	repo    repository
+	images  *imageCache // nil when caching is disabled
+	offline bool
+	// concurrency limits the number of simultaneous requests for
+	// contributor profiles and avatars.
+	concurrency int
+
+	updateUsers   chan usersResult
+	commitsResult chan commitsResult
+	commitDetail  chan commitDetail
+	activity      chan activityResult
 	ctx           context.Context
 	ctxCancel     context.CancelFunc
+	// fetchCtx is cancelled by fetchCancel when switching repository.
+	fetchCtx    context.Context
+	fetchCancel context.CancelFunc
+
+	// Signing in.
+	creds         *credentials
+	apiURL        string // identifies the forge in the tokens file
+	oauthClientID string // for device flow login, if supported
+	loginEvents   chan loginEvent
+	loginCancel   context.CancelFunc
+}
+
+// repository identifies a GitHub repository.
+type repository struct {
+	owner, name string
+}
+
+// usersResult carries a page of contributors of a repository.
+type usersResult struct {
+	repo  repository
+	users []*user
+	// done is set for the last result of a page. The contributors of
+	// a page are sent one by one as they complete, followed by a
+	// result with done set.
+	done bool
+	// next is the next page of contributors, or 0 if this was the
+	// last page. If err is set, next is the page that failed.
+	next int
+	err  error
+	// partial reports failures to fetch some of the contributors on
+	// the page, who are then left out.
+	partial error
+}
+
+// commitsResult carries a page of commits by a user.
+type commitsResult struct {
+	// page is the user page that requested the commits. Results for
+	// a page that is no longer shown are dropped, even if the same user
+	// is shown again.
+	page    *userPage
+	commits []*commit
+	// next is the next page of commits, or 0 if this was the last
+	// page. If err is set, next is the page that failed.
+	next int
+	err  error
+	// truncated reports that the forge stopped searching for commits
+	// before the end of the history.
+	truncated bool
 }
 
+// activityResult carries the activity of a user.
+type activityResult struct {
+	// page is the user page that requested the activity, as for
+	// commitsResult.
+	page     *userPage
+	activity *activity
+	err      error
+}
+
+// commitDetail carries a commit with its changed files.
+type commitDetail struct {
+	sha    string
+	commit *commit
+	err    error
+}
+
+// perPage is the number of items requested per page.
+const perPage = 30
+
 var (
-	prof  = flag.Bool("profile", false, "serve profiling data at http://localhost:6060")
-	stats = flag.Bool("stats", false, "show rendering statistics")
-	token = flag.String("token", "", "Github authentication token")
+	prof   = flag.Bool("profile", false, "serve profiling data at http://localhost:6060")
+	stats  = flag.Bool("stats", false, "show rendering statistics")
+	token  = flag.String("token", "", "authentication token; prefer the GITHUB_TOKEN or GITEA_TOKEN environment variable, or signing in from the app, to keep the token out of shell history")
+	owner  = flag.String("owner", "golang", "owner of the repository")
+	repo   = flag.String("repo", "go", "name of the repository")
+	kind   = flag.String("forge", "github", "kind of forge hosting the repository: github or gitea (including Forgejo)")
+	apiURL = flag.String("api-url", "", "base URL of the forge API, such as https://github.example.com/api/v3/ for GitHub Enterprise (default https://api.github.com/ for GitHub and https://codeberg.org/api/v1/ for Gitea)")
+
+	cacheDir = flag.String("cache-dir", defaultCacheDir(), "directory for caching API responses and avatars, or empty to disable caching")
+	offline  = flag.Bool("offline", false, "show only cached data, without accessing the network")
+
+	concurrency = flag.Int("concurrency", 8, "maximum number of concurrent requests for contributor details")
+
+	oauthClientID = flag.String("oauth-client-id", "", "client ID of a GitHub OAuth app with device flow enabled, to offer signing in through github.com")
 )
 
+// defaultCacheDir returns the default cache directory, or the empty
+// string if the system has no cache directory.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gio-gophers")
+}
+
 func main() {
 	flag.Parse()
 	initProfiling()
-	if *token == "" {
-		fmt.Println("The quota for anonymous GitHub API access is very low. Specify a token with -token to avoid quota errors.")
+	api := *apiURL
+	if api == "" {
+		api = defaultAPIURL(*kind)
+	}
+	tok := *token
+	if tok == "" {
+		var err error
+		tok, err = loadToken(tokenEnv(*kind), api)
+		if err != nil {
+			log.Printf("failed to load token: %v", err)
+		}
+	}
+	if *kind == "github" && tok == "" && !*offline {
+		fmt.Println("The quota for anonymous GitHub API access is very low. Set GITHUB_TOKEN or sign in from the app to avoid quota errors.")
 		fmt.Println("See https://help.github.com/en/articles/creating-a-personal-access-token-for-the-command-line.")
 	}
+	creds := &credentials{token: tok}
+	var (
+		transport http.RoundTripper
+		images    *imageCache
+	)
+	switch {
+	case *cacheDir != "":
+		cache, err := newHTTPCache(filepath.Join(*cacheDir, "http"), *offline, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		transport = cache
+		images, err = newImageCache(filepath.Join(*cacheDir, "avatars"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	case *offline:
+		log.Fatal("-offline requires a -cache-dir")
+	}
+	f, err := newForge(*kind, api, creds, transport)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := repository{owner: *owner, name: *repo}
 	go func() {
 		w := new(app.Window)
 		w.Option(
 			app.Size(unit.Dp(400), unit.Dp(800)),
-			app.Title("Gophers"),
 		)
-		if err := newApp(w).run(); err != nil {
+		a := newApp(w, f, r)
+		a.images = images
+		a.offline = *offline
+		a.concurrency = *concurrency
+		clientID := ""
+		if *kind == "github" && api == defaultAPIURL("github") {
+			clientID = *oauthClientID
+		}
+		a.setCredentials(creds, api, clientID)
+		if err := a.run(); err != nil {
 			log.Fatal(err)
 		}
 		os.Exit(0)
@@ -98,12 +239,71 @@ func (a *App) run() error {
 	var ops op.Ops
 	for {
 		select {
-		case users := <-a.updateUsers:
-			a.ui.users = users
-			a.ui.userClicks = make([]gesture.Click, len(users))
+		case res := <-a.updateUsers:
+			// Ignore results for a repository that is no longer shown.
+			if res.repo != a.repo {
+				break
+			}
+			a.ui.addUsers(res.users)
 			a.w.Invalidate()
-		case commits := <-a.commitsResult:
-			a.ui.selectedUser.commits = commits
+			if !res.done {
+				break
+			}
+			a.ui.usersNext = res.next
+			a.ui.usersLoading = false
+			if res.err != nil {
+				a.ui.usersFailed(res.err)
+			}
+			if res.partial != nil {
+				a.ui.report(res.partial, nil)
+			}
+			a.w.Invalidate()
+		case res := <-a.commitsResult:
+			if up := a.ui.selectedUser; up != nil && up == res.page {
+				up.addCommits(res.commits)
+				up.next = res.next
+				up.truncated = res.truncated
+				up.loading = false
+				if res.err != nil {
+					a.ui.commitsFailed(up, res.err)
+				}
+			}
+			a.w.Invalidate()
+		case res := <-a.activity:
+			if up := a.ui.selectedUser; up != nil && up == res.page {
+				up.activityLoading = false
+				if res.err != nil {
+					a.ui.activityFailed(up, res.err)
+				} else {
+					up.activity = res.activity
+				}
+			}
+			a.w.Invalidate()
+		case e := <-a.loginEvents:
+			d := a.ui.loginDialog
+			switch {
+			case d == nil:
+				// The dialog was closed.
+			case e.err != nil:
+				d.device = nil
+				d.err = e.err.Error()
+			case e.device != nil:
+				d.device = e.device
+			default:
+				a.login(e.token, d.remember.Value)
+			}
+			a.w.Invalidate()
+		case res := <-a.commitDetail:
+			if up := a.ui.selectedUser; up != nil && up.detail != nil && up.detail.sha == res.sha {
+				cp := up.detail
+				cp.loading = false
+				if res.err != nil {
+					a.ui.commitFailed(cp, res.err)
+				} else {
+					cp.commit = res.commit
+					cp.loaded = true
+				}
+			}
 			a.w.Invalidate()
 		case e := <-events:
 			switch e := e.(type) {
@@ -118,10 +318,6 @@ func (a *App) run() error {
 				if a.ctxCancel == nil {
 					a.ctx, a.ctxCancel = context.WithCancel(context.Background())
 				}
-				if a.ui.users == nil {
-					a.ui.users = []*user{}
-					go a.fetchContributors()
-				}
 				gtx := app.NewContext(&ops, e)
 
 				// register a global key listener for the escape key wrapping our entire UI.
@@ -151,8 +347,7 @@ func (a *App) run() error {
 						case key.NameEscape:
 							return nil
 						case key.NameBack:
-							if a.ui.selectedUser != nil {
-								a.ui.selectedUser = nil
+							if a.ui.back() {
 								a.w.Invalidate()
 							}
 						case "P":
@@ -172,114 +367,372 @@ func (a *App) run() error {
 	}
 }
 
-func newApp(w *app.Window) *App {
+func newApp(w *app.Window, f forge, r repository) *App {
 	a := &App{
 		w:             w,
-		updateUsers:   make(chan []*user),
-		commitsResult: make(chan []*github.Commit, 1),
+		forge:         f,
+		updateUsers:   make(chan usersResult),
+		commitsResult: make(chan commitsResult, 1),
+		commitDetail:  make(chan commitDetail, 1),
+		activity:      make(chan activityResult, 1),
+		loginEvents:   make(chan loginEvent),
 	}
-	fetch := func(u string) {
-		a.fetchCommits(a.ctx, u)
+	fetch := func(up *userPage, page int) {
+		a.fetchCommits(a.ctx, a.repo, up, page)
 	}
 	a.ui = newUI(fetch)
+	a.ui.switchRepo = a.switchRepo
+	a.ui.fetchUsers = a.fetchUsers
+	a.ui.fetchCommit = func(sha string) {
+		a.fetchCommit(a.ctx, a.repo, sha)
+	}
+	a.ui.fetchActivity = func(up *userPage) {
+		go a.fetchActivity(a.repoCtx(), a.repo, up, time.Now())
+	}
+	a.switchRepo(r)
 	return a
 }
 
-func githubClient(ctx context.Context) *github.Client {
-	var tc *http.Client
-	if *token != "" {
-		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: *token},
-		)
-		tc = oauth2.NewClient(ctx, ts)
+// switchRepo shows the contributors of r in place of the current
+// repository.
+func (a *App) switchRepo(r repository) {
+	a.repo = r
+	a.ui.repo = r
+	a.ui.clearUsers()
+	a.ui.usersNext = 1
+	a.ui.usersLoading = false
+	a.ui.selectedUser = nil
+	if a.fetchCancel != nil {
+		a.fetchCancel()
+		a.fetchCancel = nil
+	}
+	title := "Gophers - " + r.String()
+	if a.offline {
+		title += " (offline)"
+	}
+	a.w.Option(app.Title(title))
+	a.w.Invalidate()
+}
+
+func (r repository) String() string {
+	return r.owner + "/" + r.name
+}
+
+// parseRepository parses a repository in the form "owner/name".
+func parseRepository(s string) (repository, error) {
+	owner, name, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return repository{}, fmt.Errorf("invalid repository %q, expected owner/name", s)
+	}
+	return repository{owner: owner, name: name}, nil
+}
+
+// defaultAPIURL returns the API URL of the public instance of the
+// given kind of forge.
+func defaultAPIURL(kind string) string {
+	if kind == "github" {
+		return "https://api.github.com/"
+	}
+	return "https://codeberg.org/api/v1/"
+}
+
+// newForge returns the forge of the given kind with its API at apiURL,
+// authenticated with creds.
+func newForge(kind, apiURL string, creds *credentials, transport http.RoundTripper) (forge, error) {
+	switch kind {
+	case "github":
+		return newGitHub(apiURL, creds, transport)
+	case "gitea", "forgejo":
+		return newGitea(apiURL, creds, transport)
+	default:
+		return nil, fmt.Errorf("unknown forge %q, expected github or gitea", kind)
+	}
+}
+
+// setCredentials sets the credentials used by the forge, and enables
+// signing in from the app. Device flow login is offered if clientID is
+// not empty.
+func (a *App) setCredentials(creds *credentials, apiURL, clientID string) {
+	a.creds = creds
+	a.apiURL = apiURL
+	a.oauthClientID = clientID
+	a.ui.signedIn = creds.get() != ""
+	a.ui.login = a.login
+	a.ui.logout = a.logout
+	a.ui.cancelLogin = a.cancelLogin
+	a.ui.deviceLogin = nil
+	if clientID != "" {
+		a.ui.deviceLogin = a.startDeviceLogin
+	}
+}
+
+// login authenticates further requests with token, remembering it in
+// the tokens file if remember is set, and reloads the repository.
+func (a *App) login(token string, remember bool) {
+	a.cancelLogin()
+	a.creds.set(token)
+	a.ui.signedIn = true
+	a.ui.loginDialog = nil
+	if remember {
+		if err := saveToken(a.apiURL, token); err != nil {
+			a.ui.report(fmt.Errorf("failed to remember token: %w", err), nil)
+		}
 	}
-	return github.NewClient(tc)
+	a.switchRepo(a.repo)
 }
 
-func (a *App) fetchContributors() {
-	client := githubClient(a.ctx)
-	cons, _, err := client.Repositories.ListContributors(a.ctx, "golang", "go", nil)
+// logout stops authenticating requests and forgets any remembered
+// token.
+func (a *App) logout() {
+	a.creds.set("")
+	a.ui.signedIn = false
+	if err := saveToken(a.apiURL, ""); err != nil {
+		a.ui.report(fmt.Errorf("failed to forget token: %w", err), nil)
+	}
+	a.switchRepo(a.repo)
+}
+
+func (a *App) startDeviceLogin() {
+	a.cancelLogin()
+	var ctx context.Context
+	ctx, a.loginCancel = context.WithCancel(a.ctx)
+	go a.deviceLogin(ctx)
+}
+
+// cancelLogin stops waiting for a device flow login.
+func (a *App) cancelLogin() {
+	if a.loginCancel != nil {
+		a.loginCancel()
+		a.loginCancel = nil
+	}
+}
+
+// repoCtx returns the context of fetches for the current repository,
+// which is cancelled when switching repository.
+func (a *App) repoCtx() context.Context {
+	if a.fetchCancel == nil {
+		a.fetchCtx, a.fetchCancel = context.WithCancel(a.ctx)
+	}
+	return a.fetchCtx
+}
+
+// fetchUsers starts fetching a page of contributors to the current
+// repository. Fetches for a previous repository are cancelled.
+func (a *App) fetchUsers(page int) {
+	go a.fetchContributors(a.repoCtx(), a.repo, page)
+}
+
+func (a *App) fetchContributors(ctx context.Context, r repository, page int) {
+	cons, next, err := a.forge.Contributors(ctx, r, page)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "github: failed to fetch contributors: %v\n", err)
+		fmt.Fprintf(os.Stderr, "failed to fetch contributors: %v\n", err)
+		select {
+		case a.updateUsers <- usersResult{repo: r, done: true, next: page, err: fmt.Errorf("failed to load contributors: %w", err)}:
+		case <-ctx.Done():
+		}
 		return
 	}
-	var users []*user
-	userErrs := make(chan error, len(cons))
-	avatarErrs := make(chan error, len(cons))
-	for _, con := range cons {
-		con := con
-		avatar := con.GetAvatarURL()
-		if avatar == "" {
-			continue
-		}
-		u := &user{
-			login: con.GetLogin(),
+	send := func(res usersResult) bool {
+		select {
+		case a.updateUsers <- res:
+			return true
+		case <-ctx.Done():
+			return false
 		}
-		users = append(users, u)
-		go func() {
-			guser, _, err := client.Users.Get(a.ctx, u.login)
-			if err != nil {
-				avatarErrs <- err
-				return
-			}
-			u.name = guser.GetName()
-			u.company = guser.GetCompany()
-			avatarErrs <- nil
-		}()
+	}
+
+	// Fetch the profiles and avatars with a pool of workers, and
+	// stream each user to the UI as soon as it is complete.
+	type result struct {
+		user *user
+		err  error
+	}
+	jobs := make(chan contributor)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for range min(a.workers(), len(cons)) {
+		wg.Add(1)
 		go func() {
-			a, err := fetchImage(avatar)
-			if a != nil {
-				u.avatar = a
+			defer wg.Done()
+			for con := range jobs {
+				u, err := a.fetchUser(ctx, con)
+				select {
+				case results <- result{u, err}:
+				case <-ctx.Done():
+					return
+				}
 			}
-			userErrs <- err
 		}()
 	}
-	for range cons {
-		if err := <-userErrs; err != nil {
-			fmt.Fprintf(os.Stderr, "github: failed to fetch user: %v\n", err)
+	go func() {
+		defer close(jobs)
+		for _, con := range cons {
+			select {
+			case jobs <- con:
+			case <-ctx.Done():
+				return
+			}
 		}
-		if err := <-avatarErrs; err != nil {
-			fmt.Fprintf(os.Stderr, "github: failed to fetch avatar: %v\n", err)
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		failed   int
+		firstErr error
+	)
+	for res := range results {
+		switch {
+		case res.err != nil:
+			fmt.Fprintf(os.Stderr, "%v\n", res.err)
+			failed++
+			if firstErr == nil {
+				firstErr = res.err
+			}
+		case res.user != nil:
+			if !send(usersResult{repo: r, users: []*user{res.user}}) {
+				return
+			}
 		}
 	}
-	// Drop users with no avatar or name.
-	for i := len(users) - 1; i >= 0; i-- {
-		if u := users[i]; u.name == "" || u.avatar.Bounds().Size() == (image.Point{}) {
-			users = slices.Delete(users, i, i+1)
-		}
+	if ctx.Err() != nil {
+		return
 	}
-	a.updateUsers <- users
+	var partial error
+	if failed > 0 {
+		partial = fmt.Errorf("failed to load %d contributors: %w", failed, firstErr)
+	}
+	send(usersResult{repo: r, next: next, partial: partial, done: true})
+}
+
+// workers returns the number of requests to make concurrently.
+func (a *App) workers() int {
+	return max(a.concurrency, 1)
 }
 
-func fetchImage(url string) (image.Image, error) {
-	resp, err := http.Get(url)
+// fetchUser fetches the profile and avatar of a contributor. It
+// returns a nil user for contributors without a name or avatar, who
+// are not shown.
+func (a *App) fetchUser(ctx context.Context, con contributor) (*user, error) {
+	if con.avatarURL == "" {
+		return nil, nil
+	}
+	u := &user{
+		login:         con.login,
+		contributions: con.contributions,
+	}
+	p, err := a.forge.User(ctx, u.login)
 	if err != nil {
-		return nil, fmt.Errorf("fetchImage: http.Get(%q): %v", url, err)
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
 	}
-	defer resp.Body.Close()
-	img, _, err := image.Decode(resp.Body)
+	u.name = p.name
+	u.company = p.company
+	if u.name == "" {
+		return nil, nil
+	}
+	img, err := a.fetchImage(ctx, con.avatarURL)
 	if err != nil {
-		return nil, fmt.Errorf("fetchImage: image decode failed: %v", err)
+		return nil, fmt.Errorf("failed to fetch avatar: %w", err)
+	}
+	if img.Bounds().Empty() {
+		return nil, nil
+	}
+	u.avatar = img
+	return u, nil
+}
+
+// fetchImage returns the image at url, from the image cache if
+// possible.
+func (a *App) fetchImage(ctx context.Context, url string) (image.Image, error) {
+	if a.images != nil {
+		if img, err := a.images.load(url); err == nil {
+			return img, nil
+		}
+	}
+	if a.offline {
+		return nil, fmt.Errorf("fetchImage: %s: %w", url, errNotCached)
+	}
+	img, err := a.forge.Avatar(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("fetchImage: %w", err)
+	}
+	if a.images != nil {
+		if err := a.images.store(url, img); err != nil {
+			fmt.Fprintf(os.Stderr, "fetchImage: failed to cache image: %v\n", err)
+		}
 	}
 	return img, nil
 }
 
-func (a *App) fetchCommits(ctx context.Context, user string) {
+// fetchCommits fetches a page of commits by the user of up.
+func (a *App) fetchCommits(ctx context.Context, r repository, up *userPage, page int) {
+	user := up.user.login
+	send := func(res commitsResult) {
+		select {
+		case a.commitsResult <- res:
+		case <-ctx.Done():
+		}
+	}
 	go func() {
-		gh := githubClient(ctx)
-		repoCommits, _, err := gh.Repositories.ListCommits(ctx, "golang", "go", &github.CommitsListOptions{
-			Author: user,
-		})
-		if err != nil {
+		q := commitQuery{author: user, page: page, perPage: perPage}
+		commits, next, err := a.forge.Commits(ctx, r, q)
+		truncated := errors.Is(err, errTruncated)
+		if err != nil && !truncated {
 			log.Printf("failed to fetch commits: %v", err)
+			send(commitsResult{page: up, next: page, err: fmt.Errorf("failed to load commits: %w", err)})
 			return
 		}
-		var commits []*github.Commit
-		for _, commit := range repoCommits {
-			if c := commit.GetCommit(); c != nil {
-				commits = append(commits, c)
-			}
+		send(commitsResult{page: up, commits: commits, next: next, truncated: truncated})
+	}()
+}
+
+// fetchCommit fetches a commit along with its changed files, which
+// are not included in commit listings.
+func (a *App) fetchCommit(ctx context.Context, r repository, sha string) {
+	go func() {
+		commit, err := a.forge.Commit(ctx, r, sha)
+		if err != nil {
+			log.Printf("failed to fetch commit: %v", err)
+			err = fmt.Errorf("failed to load commit %.7s: %w", sha, err)
+		}
+		select {
+		case a.commitDetail <- commitDetail{sha: sha, commit: commit, err: err}:
+		case <-ctx.Done():
 		}
-		a.commitsResult <- commits
 	}()
 }
+
+// fetchActivity counts the commits by the user of up in the year before
+// now, and sends the result to a.activity.
+func (a *App) fetchActivity(ctx context.Context, r repository, up *userPage, now time.Time) {
+	user := up.user.login
+	act := newActivity(now)
+	res := activityResult{page: up, activity: act}
+	for page := 1; page != 0; {
+		if page > maxActivityPages {
+			act.truncated = true
+			break
+		}
+		q := commitQuery{author: user, since: act.start, page: page, perPage: 100}
+		commits, next, err := a.forge.Commits(ctx, r, q)
+		if errors.Is(err, errTruncated) {
+			act.truncated = true
+			err = nil
+		}
+		if err != nil {
+			log.Printf("failed to fetch activity: %v", err)
+			res = activityResult{page: up, err: fmt.Errorf("failed to load activity: %w", err)}
+			break
+		}
+		for _, c := range commits {
+			act.add(c.date)
+		}
+		page = next
+	}
+	select {
+	case a.activity <- res:
+	case <-ctx.Done():
+	}
+}
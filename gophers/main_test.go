@@ -3,15 +3,27 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"gioui.org/layout"
 	"gioui.org/op"
 )
 
 func BenchmarkUI(b *testing.B) {
-	fetch := func(_ string) {}
+	fetch := func(_ *userPage, _ int) {}
 	u := newUI(fetch)
 	var ops op.Ops
 	for b.Loop() {
@@ -22,3 +34,333 @@ func BenchmarkUI(b *testing.B) {
 		u.Layout(gtx)
 	}
 }
+
+// newTestServer returns a stand-in for the GitHub API that serves the
+// contributors of the repository example/repo under /api/, one
+// contributor per page.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	reply := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Error(err)
+		}
+	}
+	mux.HandleFunc("/api/repos/example/repo/contributors", func(w http.ResponseWriter, r *http.Request) {
+		logins := []string{"alice", "bob"}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		if page > len(logins) {
+			reply(w, []any{})
+			return
+		}
+		if page < len(logins) {
+			next := *r.URL
+			q := next.Query()
+			q.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = q.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, srv.URL, next.RequestURI()))
+		}
+		login := logins[page-1]
+		reply(w, []map[string]any{
+			{"login": login, "avatar_url": srv.URL + "/avatars/" + login + ".png"},
+		})
+	})
+	mux.HandleFunc("/api/repos/example/repo/commits", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("author") != "alice" {
+			reply(w, []any{})
+			return
+		}
+		var commits []map[string]any
+		for _, date := range []string{"2024-03-04T10:00:00Z", "2024-03-04T23:00:00Z", "2024-03-12T08:00:00Z", "2022-01-01T00:00:00Z"} {
+			commits = append(commits, map[string]any{
+				"sha": "0123456789abcdef",
+				"commit": map[string]any{
+					"message": "Fix",
+					"author":  map[string]any{"name": "Alice", "date": date},
+				},
+			})
+		}
+		reply(w, commits)
+	})
+	mux.HandleFunc("/api/users/", func(w http.ResponseWriter, r *http.Request) {
+		login := strings.TrimPrefix(r.URL.Path, "/api/users/")
+		reply(w, map[string]any{
+			"login":   login,
+			"name":    strings.ToUpper(login[:1]) + login[1:],
+			"company": "Example",
+		})
+	})
+	mux.HandleFunc("/avatars/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(w, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Error(err)
+		}
+	})
+	return srv
+}
+
+func TestFetchContributors(t *testing.T) {
+	srv := newTestServer(t)
+	f, err := newGitHub(srv.URL+"/api", new(credentials), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{
+		forge:       f,
+		updateUsers: make(chan usersResult),
+		concurrency: 2,
+	}
+	r := repository{owner: "example", name: "repo"}
+	var names []string
+	for page := 1; page != 0; {
+		go a.fetchContributors(context.Background(), r, page)
+		for res := range a.updateUsers {
+			if res.repo != r {
+				t.Errorf("got users for %v, want %v", res.repo, r)
+			}
+			for _, u := range res.users {
+				names = append(names, u.name)
+			}
+			if res.done {
+				page = res.next
+				break
+			}
+		}
+	}
+	if got, want := strings.Join(names, ","), "Alice,Bob"; got != want {
+		t.Errorf("got users %q, want %q", got, want)
+	}
+}
+
+func TestHTTPCache(t *testing.T) {
+	var revalidated, requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Etag", `"v1"`)
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	get := func(offline bool, url string) (string, error) {
+		cache, err := newHTTPCache(dir, offline, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: cache}).Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+	for i := range 2 {
+		body, err := get(false, srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "hello" {
+			t.Errorf("request %d: got %q, want %q", i, body, "hello")
+		}
+	}
+	if revalidated != 1 {
+		t.Errorf("got %d revalidations, want 1", revalidated)
+	}
+	body, err := get(true, srv.URL)
+	if err != nil || body != "hello" {
+		t.Errorf("offline: got %q, %v, want %q", body, err, "hello")
+	}
+	if requests != 2 {
+		t.Errorf("offline request reached the server")
+	}
+	if _, err := get(true, srv.URL+"/missing"); !errors.Is(err, errNotCached) {
+		t.Errorf("offline request for uncached URL returned %v, want %v", err, errNotCached)
+	}
+
+	// Responses to authenticated requests are kept from other users.
+	authGet := func(offline bool, token string) (string, error) {
+		cache, err := newHTTPCache(dir, offline, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth := &authTransport{creds: &credentials{token: token}, scheme: "token", transport: cache}
+		resp, err := (&http.Client{Transport: auth}).Get(srv.URL + "/private")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+	if _, err := authGet(false, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if body, err := authGet(true, "secret"); err != nil || body != "hello" {
+		t.Errorf("offline with the token: got %q, %v, want %q", body, err, "hello")
+	}
+	for _, token := range []string{"", "other"} {
+		if _, err := authGet(true, token); !errors.Is(err, errNotCached) {
+			t.Errorf("offline with token %q: got %v, want %v", token, err, errNotCached)
+		}
+	}
+}
+
+func TestUserFilter(t *testing.T) {
+	users := []*user{
+		{login: "carol", name: "Carol", company: "Acme", contributions: 5},
+		{login: "alice", name: "Alice", contributions: 12},
+		{login: "bob", name: "Bob", company: "acme corp", contributions: 5},
+	}
+	logins := func(idx []int) string {
+		var names []string
+		for _, i := range idx {
+			names = append(names, users[i].login)
+		}
+		return strings.Join(names, ",")
+	}
+	tests := []struct {
+		filter userFilter
+		want   string
+	}{
+		{userFilter{order: byContributions}, "alice,bob,carol"},
+		{userFilter{order: byName}, "alice,bob,carol"},
+		{userFilter{order: byCompany}, "carol,bob,alice"},
+		{userFilter{query: "acme", order: byName}, "bob,carol"},
+		{userFilter{query: "ali", order: byContributions}, "alice"},
+		{userFilter{query: "nobody", order: byName}, ""},
+	}
+	for _, test := range tests {
+		if got := logins(test.filter.apply(nil, users)); got != test.want {
+			t.Errorf("%+v: got %q, want %q", test.filter, got, test.want)
+		}
+	}
+}
+
+func BenchmarkUserFilter(b *testing.B) {
+	users := make([]*user, 5000)
+	for i := range users {
+		users[i] = &user{
+			login:         fmt.Sprintf("user%d", i),
+			name:          fmt.Sprintf("User %d", i),
+			company:       fmt.Sprintf("Company %d", i%50),
+			contributions: i % 97,
+		}
+	}
+	f := userFilter{query: "company 1", order: byName}
+	var visible []int
+	for b.Loop() {
+		visible = f.apply(visible, users)
+	}
+}
+
+func TestStatBar(t *testing.T) {
+	tests := []struct {
+		additions, deletions int
+		add, del             int
+	}{
+		{0, 0, 0, 0},
+		{2, 1, 2, 1},
+		{10, 0, 5, 0},
+		{50, 50, 3, 2},
+		{1000, 1, 4, 1},
+		{1, 1000, 1, 4},
+	}
+	for _, test := range tests {
+		add, del := statBar{test.additions, test.deletions}.blocks()
+		if add != test.add || del != test.del {
+			t.Errorf("statBar{%d, %d}.blocks() = %d, %d, want %d, %d",
+				test.additions, test.deletions, add, del, test.add, test.del)
+		}
+	}
+}
+
+func TestFetchActivity(t *testing.T) {
+	srv := newTestServer(t)
+	f, err := newGitHub(srv.URL+"/api", new(credentials), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &App{
+		forge:    f,
+		activity: make(chan activityResult, 1),
+	}
+	r := repository{owner: "example", name: "repo"}
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	up := &userPage{user: &user{login: "alice"}}
+	a.fetchActivity(context.Background(), r, up, now)
+	res := <-a.activity
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.page != up {
+		t.Errorf("activity for page %p, want %p", res.page, up)
+	}
+	act := res.activity
+	if got, want := act.total(), 3; got != want {
+		t.Errorf("got %d commits, want %d", got, want)
+	}
+	day := int(time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC).Sub(act.start).Hours() / 24)
+	if got, want := act.days[day], 2; got != want {
+		t.Errorf("got %d commits on March 4, want %d", got, want)
+	}
+	if got, want := act.week(day/7), 2; got != want {
+		t.Errorf("got %d commits in the week of March 4, want %d", got, want)
+	}
+	if got, want := act.week(day/7+1), 1; got != want {
+		t.Errorf("got %d commits in the week of March 11, want %d", got, want)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GITHUB_TOKEN", "")
+	const api = "https://api.github.com/"
+	load := func() string {
+		t.Helper()
+		tok, err := loadToken("GITHUB_TOKEN", api)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	if tok := load(); tok != "" {
+		t.Errorf("got token %q before saving one", tok)
+	}
+	if err := saveToken(api, "saved"); err != nil {
+		t.Fatal(err)
+	}
+	if tok := load(); tok != "saved" {
+		t.Errorf("got token %q, want the saved token", tok)
+	}
+	path, err := tokensFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if perm := fi.Mode().Perm(); perm&0o077 != 0 {
+		t.Errorf("tokens file has mode %v, want it private", perm)
+	}
+	t.Setenv("GITHUB_TOKEN", "env")
+	if tok := load(); tok != "env" {
+		t.Errorf("got token %q, want the environment token", tok)
+	}
+	t.Setenv("GITHUB_TOKEN", "")
+	if err := saveToken(api, ""); err != nil {
+		t.Fatal(err)
+	}
+	if tok := load(); tok != "" {
+		t.Errorf("got token %q after forgetting it", tok)
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v24/github"
+)
+
+// rateGate holds back API requests while the GitHub rate limit is
+// exhausted. It learns the limit from the rate headers of every
+// response. Limits apply per token, so a limit reached anonymously
+// doesn't hold back requests after signing in.
+type rateGate struct {
+	mu    sync.Mutex
+	token string // the token whose limit is exhausted
+	reset time.Time
+}
+
+// rateLimitError reports that the rate limit is exhausted until reset.
+type rateLimitError struct {
+	reset time.Time
+}
+
+func (e *rateLimitError) Error() string {
+	wait := time.Until(e.reset).Round(time.Minute)
+	return fmt.Sprintf("GitHub rate limit exceeded, resets at %s (in %v)", e.reset.Format(time.Kitchen), max(wait, time.Minute))
+}
+
+// do calls f unless the rate limit of token is known to be exhausted,
+// and records the rate limit reported by its response. Errors caused
+// by the rate limit are returned as *rateLimitError.
+func (g *rateGate) do(token string, f func() (*github.Response, error)) error {
+	if err := g.check(token); err != nil {
+		return err
+	}
+	resp, err := f()
+	g.update(token, resp, err)
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return &rateLimitError{reset: rateErr.Rate.Reset.Time}
+	}
+	return err
+}
+
+// check returns a *rateLimitError if the rate limit of token has not
+// yet reset.
+func (g *rateGate) check(token string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if token == g.token && time.Now().Before(g.reset) {
+		return &rateLimitError{reset: g.reset}
+	}
+	return nil
+}
+
+// update records the rate limit of token reported by a response and
+// its error.
+func (g *rateGate) update(token string, resp *github.Response, err error) {
+	var reset time.Time
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	switch {
+	case errors.As(err, &rateErr):
+		reset = rateErr.Rate.Reset.Time
+	case errors.As(err, &abuseErr) && abuseErr.RetryAfter != nil:
+		reset = time.Now().Add(*abuseErr.RetryAfter)
+	case resp != nil && resp.Rate.Limit > 0 && resp.Rate.Remaining == 0:
+		reset = resp.Rate.Reset.Time
+	default:
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if token != g.token || reset.After(g.reset) {
+		g.token = token
+		g.reset = reset
+	}
+}
@@ -5,16 +5,20 @@ package main
 // A Gio program that displays Go contributors from GitHub. See https://gioui.org for more information.
 
 import (
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"runtime"
+	"slices"
+	"strings"
 
 	"gioui.org/font"
 	"gioui.org/font/gofont"
 	"gioui.org/gesture"
 	"gioui.org/io/key"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
@@ -24,8 +28,6 @@ import (
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 
-	"github.com/google/go-github/v24/github"
-
 	"golang.org/x/exp/shiny/materialdesign/icons"
 
 	"golang.org/x/image/draw"
@@ -34,12 +36,50 @@ import (
 type UI struct {
 	fab          *widget.Clickable
 	fabIcon      *widget.Icon
+	backIcon     *widget.Icon
 	usersList    *widget.List
 	users        []*user
-	userClicks   []gesture.Click
+	userClicks   []gesture.Click // indexed like users
 	selectedUser *userPage
 	edit, edit2  *widget.Editor
-	fetchCommits func(u string)
+	fetchCommits func(up *userPage, page int)
+	fetchCommit  func(sha string)
+	// fetchActivity fetches the commits of the user of a page in the
+	// last year.
+	fetchActivity func(up *userPage)
+
+	// Pagination of users.
+	usersLoading bool
+	usersNext    int // next page of users to load, or 0 if there are no more
+	fetchUsers   func(page int)
+	usersErr     error
+	usersRetry   *widget.Clickable
+	usersMore    *widget.Clickable // loads the next page while searching
+
+	banners []*banner
+
+	// Searching and sorting of users.
+	search  *widget.Editor
+	sortBy  widget.Enum
+	filter  userFilter
+	visible []int // indices into users of the users shown, in order
+	refresh bool  // visible is out of date
+
+	// Repository switching.
+	repo       repository
+	repoEdit   *widget.Editor
+	repoOpen   *widget.Clickable
+	repoErr    string
+	switchRepo func(r repository)
+
+	// Signing in.
+	signIn      widget.Clickable
+	signedIn    bool
+	loginDialog *loginDialog                      // nil unless signing in
+	login       func(token string, remember bool) // nil if signing in is not supported
+	logout      func()
+	deviceLogin func() // nil if device flow login is not supported
+	cancelLogin func()
 
 	// Profiling.
 	profiling   bool
@@ -47,17 +87,77 @@ type UI struct {
 }
 
 type userPage struct {
-	user        *user
-	commitsList *widget.List
-	commits     []*github.Commit
+	user         *user
+	commitsList  *widget.List
+	commits      []*commit
+	commitClicks []gesture.Click // indexed like commits
+	fetchCommits func(up *userPage, page int)
+	fetchCommit  func(sha string)
+	loading      bool
+	next         int  // next page of commits to load, or 0 if there are no more
+	truncated    bool // the forge searched only the recent history
+	err          error
+	retry        widget.Clickable
+	detail       *commitPage // the commit shown, if any
+	tab          widget.Enum
+
+	// Activity charts.
+	fetchActivity   func(up *userPage)
+	activity        *activity
+	activityLoading bool
+	activityErr     error
+	activityRetry   widget.Clickable
+}
+
+// userTab is a view of a user.
+type userTab string
+
+const (
+	commitsTab  userTab = "commits"
+	activityTab userTab = "activity"
+)
+
+// bannerKind identifies what a banner reports on, so that banners can
+// be removed when the problem is retried.
+type bannerKind int
+
+const (
+	bannerInfo bannerKind = iota
+	bannerUsers
+	bannerCommits
+	bannerCommit
+	bannerActivity
+)
+
+// banner is a dismissible notification shown above the content.
+type banner struct {
+	kind       bannerKind
+	text       string
+	retry      func() // nil if there is nothing to retry
+	retryBtn   widget.Clickable
+	dismissBtn widget.Clickable
 }
 
+// maxBanners is the number of banners shown at most. Older banners are
+// dropped first.
+const maxBanners = 3
+
+// loadAhead is the number of items from the end of a list at which the
+// next page starts loading.
+const loadAhead = 10
+
+// placeholders is the number of placeholder rows shown while a page
+// loads.
+const placeholders = 3
+
 type user struct {
-	name     string
-	login    string
-	company  string
-	avatar   image.Image
-	avatarOp paint.ImageOp
+	name          string
+	login         string
+	company       string
+	contributions int
+	avatar        image.Image
+	avatarOp      paint.ImageOp
+	lower         *userKeys
 }
 
 var theme *material.Theme
@@ -73,7 +173,7 @@ func init() {
 	theme.Palette.Fg = rgb(0x333333)
 }
 
-func newUI(fetchCommits func(string)) *UI {
+func newUI(fetchCommits func(*userPage, int)) *UI {
 	u := &UI{
 		fetchCommits: fetchCommits,
 	}
@@ -92,12 +192,26 @@ func newUI(fetchCommits func(string)) *UI {
 	if err != nil {
 		log.Fatal(err)
 	}
+	u.backIcon, err = widget.NewIcon(icons.NavigationArrowBack)
+	if err != nil {
+		log.Fatal(err)
+	}
 	u.edit2.SetText("Single line editor. Edit me!")
 	u.edit = &widget.Editor{
 		// Alignment: text.End,
 		// SingleLine: true,
 	}
 	u.edit.SetText(longTextSample)
+	u.repoEdit = &widget.Editor{
+		SingleLine: true,
+		Submit:     true,
+	}
+	u.repoOpen = new(widget.Clickable)
+	u.usersRetry = new(widget.Clickable)
+	u.usersMore = new(widget.Clickable)
+	u.search = &widget.Editor{SingleLine: true}
+	u.sortBy.Value = string(byContributions)
+	u.filter.order = byContributions
 	return u
 }
 
@@ -128,6 +242,16 @@ func (u *UI) layoutTimings(gtx layout.Context) {
 }
 
 func (u *UI) Layout(gtx layout.Context) {
+	u.updateLogin(gtx)
+	u.updateRepo(gtx)
+	u.updateFilter(gtx)
+	u.updateBanners(gtx)
+	if u.usersRetry.Clicked(gtx) {
+		u.retryUsers()
+	}
+	if u.usersMore.Clicked(gtx) && u.canLoadUsers() {
+		u.loadUsers()
+	}
 	for i := range u.userClicks {
 		click := &u.userClicks[i]
 		for {
@@ -140,39 +264,423 @@ func (u *UI) Layout(gtx layout.Context) {
 			}
 		}
 	}
-	if u.selectedUser == nil {
-		u.layoutUsers(gtx)
-	} else {
-		u.selectedUser.Layout(gtx)
+	if up := u.selectedUser; up != nil {
+		if up.retry.Clicked(gtx) {
+			u.retryCommits(up)
+		}
+		if up.activityRetry.Clicked(gtx) {
+			u.retryActivity(up)
+		}
+		up.update(gtx)
+		if cp := up.detail; cp != nil {
+			if cp.retry.Clicked(gtx) {
+				u.retryCommit(cp)
+			}
+			if cp.back.Clicked(gtx) {
+				up.detail = nil
+			}
+		}
 	}
+	column().Layout(gtx,
+		layout.Rigid(u.layoutBanners),
+		layout.Flexed(1, func(gtx C) D {
+			switch up := u.selectedUser; {
+			case up == nil:
+				u.layoutUsers(gtx)
+			case up.detail != nil:
+				up.detail.Layout(gtx, u.backIcon)
+			default:
+				up.Layout(gtx)
+			}
+			if d := u.loginDialog; d != nil {
+				gtx.Constraints.Min = gtx.Constraints.Max
+				d.Layout(gtx, u.deviceLogin != nil)
+			}
+			return D{Size: gtx.Constraints.Max}
+		}),
+	)
 	u.layoutTimings(gtx)
 }
 
+// addUsers appends users to the list of users.
+func (u *UI) addUsers(users []*user) {
+	u.users = append(u.users, users...)
+	u.userClicks = append(u.userClicks, make([]gesture.Click, len(users))...)
+	u.refresh = true
+}
+
+// clearUsers empties the list of users.
+func (u *UI) clearUsers() {
+	u.users = nil
+	u.userClicks = nil
+	u.visible = u.visible[:0]
+	u.refresh = true
+}
+
+// updateFilter applies changes to the search query and sort order, and
+// recomputes the visible users if needed. Filtering is skipped while
+// nothing changes, so that long lists lay out quickly.
+func (u *UI) updateFilter(gtx layout.Context) {
+	for {
+		e, ok := u.search.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := e.(widget.ChangeEvent); ok {
+			q := strings.ToLower(strings.TrimSpace(u.search.Text()))
+			if q != u.filter.query {
+				u.filter.query = q
+				u.refresh = true
+				u.usersList.Position = layout.Position{}
+			}
+		}
+	}
+	if u.sortBy.Update(gtx) {
+		u.filter.order = userOrder(u.sortBy.Value)
+		u.refresh = true
+		u.usersList.Position = layout.Position{}
+	}
+	if !u.refresh {
+		return
+	}
+	u.refresh = false
+	u.visible = u.filter.apply(u.visible, u.users)
+}
+
+// back leaves the commit or user shown, and reports whether there was
+// one to leave.
+func (u *UI) back() bool {
+	switch up := u.selectedUser; {
+	case up == nil:
+		return false
+	case up.detail != nil:
+		up.detail = nil
+	default:
+		u.selectedUser = nil
+	}
+	return true
+}
+
+// report shows err in a banner. If retry is not nil, the banner offers
+// to call it.
+func (u *UI) report(err error, retry func()) {
+	u.reportKind(bannerInfo, err, retry)
+}
+
+func (u *UI) reportKind(kind bannerKind, err error, retry func()) {
+	text := err.Error()
+	var rateErr *rateLimitError
+	switch {
+	case errors.Is(err, errNotCached):
+		text += ". Run without -offline to fetch it."
+	case errors.As(err, &rateErr) && !u.signedIn && u.login != nil:
+		text += ". Sign in to raise the limit."
+	}
+	for _, b := range u.banners {
+		if b.text == text {
+			return
+		}
+	}
+	u.banners = append(u.banners, &banner{kind: kind, text: text, retry: retry})
+	if n := len(u.banners); n > maxBanners {
+		u.banners = slices.Delete(u.banners, 0, n-maxBanners)
+	}
+}
+
+// dismiss removes the banners of the given kind.
+func (u *UI) dismiss(kind bannerKind) {
+	u.banners = slices.DeleteFunc(u.banners, func(b *banner) bool {
+		return b.kind == kind
+	})
+}
+
+// usersFailed records a failure to load a page of users. No more
+// pages are loaded until the user retries.
+func (u *UI) usersFailed(err error) {
+	u.usersErr = err
+	u.reportKind(bannerUsers, err, u.retryUsers)
+}
+
+func (u *UI) retryUsers() {
+	u.usersErr = nil
+	u.dismiss(bannerUsers)
+}
+
+// commitsFailed records a failure to load a page of commits for up.
+func (u *UI) commitsFailed(up *userPage, err error) {
+	up.err = err
+	u.reportKind(bannerCommits, err, func() { u.retryCommits(up) })
+}
+
+func (u *UI) retryCommits(up *userPage) {
+	up.err = nil
+	u.dismiss(bannerCommits)
+}
+
+// activityFailed records a failure to load the activity of up.
+func (u *UI) activityFailed(up *userPage, err error) {
+	up.activityErr = err
+	u.reportKind(bannerActivity, err, func() { u.retryActivity(up) })
+}
+
+func (u *UI) retryActivity(up *userPage) {
+	up.activityErr = nil
+	u.dismiss(bannerActivity)
+}
+
+// commitFailed records a failure to load the details of a commit.
+func (u *UI) commitFailed(cp *commitPage, err error) {
+	cp.err = err
+	u.reportKind(bannerCommit, err, func() { u.retryCommit(cp) })
+}
+
+func (u *UI) retryCommit(cp *commitPage) {
+	cp.err = nil
+	u.dismiss(bannerCommit)
+}
+
+func (u *UI) updateBanners(gtx layout.Context) {
+	for i := 0; i < len(u.banners); i++ {
+		b := u.banners[i]
+		if b.retry != nil && b.retryBtn.Clicked(gtx) {
+			// The retry function may remove banners.
+			b.retry()
+			u.banners = slices.DeleteFunc(u.banners, func(o *banner) bool { return o == b })
+			break
+		}
+		if b.dismissBtn.Clicked(gtx) {
+			u.banners = slices.Delete(u.banners, i, i+1)
+			i--
+		}
+	}
+}
+
+func (u *UI) layoutBanners(gtx layout.Context) layout.Dimensions {
+	if len(u.banners) == 0 {
+		return D{}
+	}
+	children := make([]layout.FlexChild, len(u.banners))
+	for i, b := range u.banners {
+		children[i] = layout.Rigid(b.Layout)
+	}
+	return column().Layout(gtx, children...)
+}
+
+func (b *banner) Layout(gtx layout.Context) layout.Dimensions {
+	gtx.Constraints.Min.X = gtx.Constraints.Max.X
+	return layout.Stack{}.Layout(gtx,
+		layout.Expanded(fill{rgb(0xfdecea)}.Layout),
+		layout.Stacked(func(gtx C) D {
+			in := layout.Inset{Top: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(16), Right: unit.Dp(8)}
+			return in.Layout(gtx, func(gtx C) D {
+				return centerRowOpts().Layout(gtx,
+					layout.Flexed(1, func(gtx C) D {
+						gtx.Constraints.Min.X = gtx.Constraints.Max.X
+						lbl := material.Body2(theme, b.text)
+						lbl.Color = rgb(0x611a15)
+						return lbl.Layout(gtx)
+					}),
+					layout.Rigid(func(gtx C) D {
+						if b.retry == nil {
+							return D{}
+						}
+						return textButton(gtx, &b.retryBtn, "RETRY")
+					}),
+					layout.Rigid(func(gtx C) D {
+						return textButton(gtx, &b.dismissBtn, "DISMISS")
+					}),
+				)
+			})
+		}),
+	)
+}
+
+// textButton lays out a borderless button labelled txt.
+func textButton(gtx layout.Context, btn *widget.Clickable, txt string) layout.Dimensions {
+	b := material.Button(theme, btn, txt)
+	b.Background = color.NRGBA{}
+	b.Color = theme.ContrastBg
+	b.TextSize = unit.Sp(12)
+	b.Inset = layout.UniformInset(unit.Dp(8))
+	return b.Layout(gtx)
+}
+
+// layoutStatus lays out a message describing the state of an empty or
+// failed list, with a retry button if retry is not nil.
+func layoutStatus(gtx layout.Context, txt string, retry *widget.Clickable) layout.Dimensions {
+	return layoutStatusButton(gtx, txt, retry, "RETRY")
+}
+
+// layoutStatusButton is like layoutStatus, with a button labelled
+// label.
+func layoutStatusButton(gtx layout.Context, txt string, btn *widget.Clickable, label string) layout.Dimensions {
+	in := layout.UniformInset(unit.Dp(16))
+	return in.Layout(gtx, func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				lbl := material.Body2(theme, txt)
+				lbl.Color = rgb(0x888888)
+				lbl.Alignment = text.Middle
+				return lbl.Layout(gtx)
+			}),
+			layout.Rigid(func(gtx C) D {
+				if btn == nil {
+					return D{}
+				}
+				return textButton(gtx, btn, label)
+			}),
+		)
+	})
+}
+
+// updateRepo switches to the repository entered in the repository
+// editor when it is submitted.
+func (u *UI) updateRepo(gtx layout.Context) {
+	submit := u.repoOpen.Clicked(gtx)
+	for {
+		e, ok := u.repoEdit.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := e.(widget.SubmitEvent); ok {
+			submit = true
+		}
+	}
+	if !submit {
+		return
+	}
+	r, err := parseRepository(u.repoEdit.Text())
+	if err != nil {
+		u.repoErr = err.Error()
+		return
+	}
+	u.repoErr = ""
+	u.repoEdit.SetText("")
+	if u.switchRepo != nil && r != u.repo {
+		u.switchRepo(r)
+	}
+}
+
 func (u *UI) newUserPage(user *user) *userPage {
 	up := &userPage{
-		user:        user,
-		commitsList: &widget.List{List: layout.List{Axis: layout.Vertical}},
+		user:          user,
+		commitsList:   &widget.List{List: layout.List{Axis: layout.Vertical}},
+		fetchCommits:  u.fetchCommits,
+		fetchCommit:   u.fetchCommit,
+		fetchActivity: u.fetchActivity,
+		next:          1,
 	}
-	u.fetchCommits(user.login)
+	up.tab.Value = string(commitsTab)
 	return up
 }
 
+// update opens the commits that were clicked.
+func (up *userPage) update(gtx layout.Context) {
+	for i := range up.commitClicks {
+		click := &up.commitClicks[i]
+		for {
+			e, ok := click.Update(gtx.Source)
+			if !ok {
+				break
+			}
+			if e.Kind == gesture.KindClick {
+				up.detail = newCommitPage(up.user, up.commits[i], up.fetchCommit)
+			}
+		}
+	}
+}
+
+// nearEnd reports whether the list is scrolled to within loadAhead
+// items of the last of n items.
+func nearEnd(l *widget.List, n int) bool {
+	pos := l.Position
+	return pos.First+pos.Count >= n-loadAhead
+}
+
 func (up *userPage) Layout(gtx layout.Context) {
+	column().Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return layout.Stack{}.Layout(gtx,
+				layout.Expanded(fill{rgb(0xf2f2f2)}.Layout),
+				layout.Stacked(func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					in := layout.Inset{Right: unit.Dp(8), Left: unit.Dp(8)}
+					return in.Layout(gtx, func(gtx C) D {
+						return centerRowOpts().Layout(gtx,
+							layout.Flexed(1, func(gtx C) D {
+								lbl := material.Caption(theme, strings.ToUpper(up.user.name))
+								lbl.Color = rgb(0x888888)
+								return lbl.Layout(gtx)
+							}),
+							layout.Rigid(material.RadioButton(theme, &up.tab, string(commitsTab), "Commits").Layout),
+							layout.Rigid(material.RadioButton(theme, &up.tab, string(activityTab), "Activity").Layout),
+						)
+					})
+				}),
+			)
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			if userTab(up.tab.Value) == activityTab {
+				return up.layoutActivity(gtx)
+			}
+			return up.layoutCommits(gtx)
+		}),
+	)
+}
+
+func (up *userPage) layoutCommits(gtx layout.Context) layout.Dimensions {
 	l := up.commitsList
 	if l.List.Dragging() {
 		gtx.Execute(key.SoftKeyboardCmd{Show: false})
 	}
-	material.List(theme, l).Layout(gtx, len(up.commits), func(gtx C, i int) D {
-		return up.commit(gtx, i)
+	if !up.loading && up.err == nil && up.next > 0 && nearEnd(l, len(up.commits)) {
+		up.loading = true
+		up.fetchCommits(up, up.next)
+	}
+	switch {
+	case len(up.commits) > 0 || up.loading:
+	case up.err != nil:
+		return layout.Center.Layout(gtx, func(gtx C) D {
+			return layoutStatus(gtx, "Couldn't load commits.", &up.retry)
+		})
+	case up.truncated:
+		return layout.Center.Layout(gtx, func(gtx C) D {
+			return layoutStatus(gtx, "No commits by "+up.user.login+" in the recent history.", nil)
+		})
+	default:
+		return layout.Center.Layout(gtx, func(gtx C) D {
+			return layoutStatus(gtx, "No commits by "+up.user.login+".", nil)
+		})
+	}
+	n := len(up.commits)
+	if up.loading {
+		n += placeholders
+	} else if up.err != nil || up.truncated {
+		n++
+	}
+	return material.List(theme, l).Layout(gtx, n, func(gtx C, i int) D {
+		switch {
+		case i < len(up.commits):
+			return up.commit(gtx, i)
+		case up.err != nil:
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layoutStatus(gtx, "Couldn't load more commits.", &up.retry)
+		case up.truncated:
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layoutStatus(gtx, "Older commits were not searched.", nil)
+		default:
+			return layoutPlaceholder(gtx)
+		}
 	})
 }
 
 func (up *userPage) commit(gtx layout.Context, index int) layout.Dimensions {
 	u := up.user
-	msg := up.commits[index].GetMessage()
+	msg := up.commits[index].message
 	label := material.Caption(theme, msg)
-	in := layout.Inset{Top: unit.Dp(16), Right: unit.Dp(8), Left: unit.Dp(8)}
-	return in.Layout(gtx, func(gtx C) D {
+	in := layout.Inset{Top: unit.Dp(8), Right: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(8)}
+	dims := in.Layout(gtx, func(gtx C) D {
 		return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 			layout.Rigid(func(gtx C) D {
 				sz := gtx.Dp(unit.Dp(48))
@@ -188,6 +696,18 @@ func (up *userPage) commit(gtx layout.Context, index int) layout.Dimensions {
 			}),
 		)
 	})
+	pr := clip.Rect(image.Rectangle{Max: dims.Size}).Push(gtx.Ops)
+	click := &up.commitClicks[index]
+	click.Add(gtx.Ops)
+	pointer.CursorPointer.Add(gtx.Ops)
+	pr.Pop()
+	return dims
+}
+
+// addCommits appends commits to the list of commits.
+func (up *userPage) addCommits(commits []*commit) {
+	up.commits = append(up.commits, commits...)
+	up.commitClicks = append(up.commitClicks, make([]gesture.Click, len(commits))...)
 }
 
 func (u *UI) layoutUsers(gtx layout.Context) {
@@ -213,6 +733,16 @@ func (u *UI) layoutUsers(gtx layout.Context) {
 						return e.Layout(gtx)
 					})
 				}),
+				layout.Rigid(func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					in := layout.Inset{Bottom: unit.Dp(16), Left: unit.Dp(16), Right: unit.Dp(16)}
+					return in.Layout(gtx, u.layoutRepoSwitcher)
+				}),
+				layout.Rigid(func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					in := layout.Inset{Bottom: unit.Dp(8), Left: unit.Dp(16), Right: unit.Dp(16)}
+					return in.Layout(gtx, u.layoutSearch)
+				}),
 				layout.Rigid(func(gtx C) D {
 					return layout.Stack{}.Layout(gtx,
 						layout.Expanded(func(gtx C) D {
@@ -220,12 +750,27 @@ func (u *UI) layoutUsers(gtx layout.Context) {
 							return fill{rgb(0xf2f2f2)}.Layout(gtx)
 						}),
 						layout.Stacked(func(gtx C) D {
-							in := layout.Inset{Top: unit.Dp(16), Right: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(8)}
-							return in.Layout(gtx, func(gtx C) D {
-								lbl := material.Caption(theme, "GOPHERS")
-								lbl.Color = rgb(0x888888)
-								return lbl.Layout(gtx)
-							})
+							gtx.Constraints.Min.X = gtx.Constraints.Max.X
+							return centerRowOpts().Layout(gtx,
+								layout.Flexed(1, func(gtx C) D {
+									in := layout.Inset{Top: unit.Dp(16), Right: unit.Dp(8), Bottom: unit.Dp(8), Left: unit.Dp(8)}
+									return in.Layout(gtx, func(gtx C) D {
+										lbl := material.Caption(theme, "GOPHERS OF "+strings.ToUpper(u.repo.String()))
+										lbl.Color = rgb(0x888888)
+										return lbl.Layout(gtx)
+									})
+								}),
+								layout.Rigid(func(gtx C) D {
+									if u.login == nil {
+										return D{}
+									}
+									txt := "SIGN IN"
+									if u.signedIn {
+										txt = "SIGN OUT"
+									}
+									return textButton(gtx, &u.signIn, txt)
+								}),
+							)
 						}),
 					)
 				}),
@@ -246,13 +791,160 @@ func (u *UI) layoutUsers(gtx layout.Context) {
 	)
 }
 
+// layoutSearch lays out the search field and sort options of the
+// contributor list.
+func (u *UI) layoutSearch(gtx layout.Context) layout.Dimensions {
+	return column().Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			e := material.Editor(theme, u.search, "Search by login, name or company")
+			e.TextSize = unit.Sp(14)
+			return e.Layout(gtx)
+		}),
+		layout.Rigid(func(gtx C) D {
+			radio := func(order userOrder, label string) layout.FlexChild {
+				return layout.Rigid(func(gtx C) D {
+					rb := material.RadioButton(theme, &u.sortBy, string(order), label)
+					rb.TextSize = unit.Sp(12)
+					return rb.Layout(gtx)
+				})
+			}
+			return centerRowOpts().Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					lbl := material.Caption(theme, "Sort by")
+					lbl.Color = rgb(0x888888)
+					return layout.Inset{Right: unit.Dp(4)}.Layout(gtx, lbl.Layout)
+				}),
+				radio(byContributions, "Contributions"),
+				radio(byName, "Name"),
+				radio(byCompany, "Company"),
+			)
+		}),
+	)
+}
+
+func (u *UI) layoutRepoSwitcher(gtx layout.Context) layout.Dimensions {
+	return column().Layout(gtx,
+		layout.Rigid(func(gtx C) D {
+			return centerRowOpts().Layout(gtx,
+				layout.Flexed(1, func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Constraints.Max.X
+					e := material.Editor(theme, u.repoEdit, "owner/repository")
+					e.TextSize = unit.Sp(14)
+					return e.Layout(gtx)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Inset{Left: unit.Dp(8)}.Layout(gtx,
+						material.Button(theme, u.repoOpen, "Open").Layout)
+				}),
+			)
+		}),
+		layout.Rigid(func(gtx C) D {
+			if u.repoErr == "" {
+				return D{}
+			}
+			lbl := material.Caption(theme, u.repoErr)
+			lbl.Color = rgb(0xc62828)
+			return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, lbl.Layout)
+		}),
+	)
+}
+
+// canLoadUsers reports whether the next page of users can be loaded.
+func (u *UI) canLoadUsers() bool {
+	return !u.usersLoading && u.usersErr == nil && u.usersNext > 0 && u.fetchUsers != nil
+}
+
+// loadUsers starts loading the next page of users.
+func (u *UI) loadUsers() {
+	u.usersLoading = true
+	u.fetchUsers(u.usersNext)
+}
+
 func (u *UI) layoutContributors(gtx layout.Context) layout.Dimensions {
 	l := u.usersList
 	if l.List.Dragging() {
 		gtx.Execute(key.SoftKeyboardCmd{Show: false})
 	}
-	return material.List(theme, l).Layout(gtx, len(u.users), func(gtx C, i int) D {
-		return u.user(gtx, i)
+	// While searching, few of the loaded users may match, and loading
+	// pages until the list fills could load every contributor. Load
+	// more on request instead.
+	searching := u.filter.query != ""
+	if !searching && u.canLoadUsers() && nearEnd(l, len(u.visible)) {
+		u.loadUsers()
+	}
+	more := searching && u.canLoadUsers()
+	switch {
+	case len(u.visible) > 0 || u.usersLoading:
+	case len(u.users) > 0:
+		return layout.Center.Layout(gtx, func(gtx C) D {
+			txt := fmt.Sprintf("No contributors match %q.", u.search.Text())
+			if more {
+				return layoutStatusButton(gtx, txt+" Search more contributors?", u.usersMore, "LOAD MORE")
+			}
+			return layoutStatus(gtx, txt, nil)
+		})
+	case u.usersErr != nil:
+		return layout.Center.Layout(gtx, func(gtx C) D {
+			return layoutStatus(gtx, "Couldn't load contributors.", u.usersRetry)
+		})
+	case u.usersNext == 0:
+		return layout.Center.Layout(gtx, func(gtx C) D {
+			return layoutStatus(gtx, "No contributors found.", nil)
+		})
+	}
+	n := len(u.visible)
+	if u.usersLoading {
+		n += placeholders
+	} else if u.usersErr != nil || more {
+		n++
+	}
+	return material.List(theme, l).Layout(gtx, n, func(gtx C, i int) D {
+		switch {
+		case i < len(u.visible):
+			return u.user(gtx, u.visible[i])
+		case u.usersErr != nil:
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layoutStatus(gtx, "Couldn't load more contributors.", u.usersRetry)
+		case more:
+			gtx.Constraints.Min.X = gtx.Constraints.Max.X
+			return layoutStatusButton(gtx, fmt.Sprintf("Searched %d contributors.", len(u.users)), u.usersMore, "LOAD MORE")
+		default:
+			return layoutPlaceholder(gtx)
+		}
+	})
+}
+
+// layoutPlaceholder lays out a gray stand-in for a row that is still
+// loading.
+func layoutPlaceholder(gtx layout.Context) layout.Dimensions {
+	gray := rgb(0xeeeeee)
+	in := layout.UniformInset(unit.Dp(8))
+	return in.Layout(gtx, func(gtx C) D {
+		return centerRowOpts().Layout(gtx,
+			layout.Rigid(func(gtx C) D {
+				sz := gtx.Dp(unit.Dp(48))
+				rr := sz / 2
+				paint.FillShape(gtx.Ops, gray, clip.UniformRRect(image.Rectangle{Max: image.Pt(sz, sz)}, rr).Op(gtx.Ops))
+				return D{Size: image.Pt(sz, sz)}
+			}),
+			layout.Flexed(1, func(gtx C) D {
+				bar := func(width float32, height unit.Dp) layout.Widget {
+					return func(gtx C) D {
+						sz := image.Pt(int(float32(gtx.Constraints.Max.X)*width), gtx.Dp(height))
+						paint.FillShape(gtx.Ops, gray, clip.Rect{Max: sz}.Op())
+						return D{Size: sz}
+					}
+				}
+				return layout.Inset{Left: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+					return column().Layout(gtx,
+						layout.Rigid(bar(.6, 14)),
+						layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+						layout.Rigid(bar(.4, 10)),
+					)
+				})
+			}),
+		)
 	})
 }
 
@@ -282,7 +974,7 @@ func (u *UI) user(gtx layout.Context, index int) layout.Dimensions {
 								gtx.Constraints.Min.X = gtx.Constraints.Max.X
 								return layout.E.Layout(gtx, func(gtx C) D {
 									return layout.Inset{Left: unit.Dp(2)}.Layout(gtx,
-										material.Caption(theme, "3 hours ago").Layout)
+										material.Caption(theme, plural(user.contributions, "contribution")).Layout)
 								})
 							}),
 						)
@@ -332,6 +1024,14 @@ func (f fill) Layout(gtx layout.Context) layout.Dimensions {
 	return layout.Dimensions{Size: d}
 }
 
+// plural formats a count of things.
+func plural(n int, thing string) string {
+	if n == 1 {
+		return "1 " + thing
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
+
 func column() layout.Flex {
 	return layout.Flex{Axis: layout.Vertical}
 }
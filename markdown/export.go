@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gioui.org/font/gofont"
+	"gioui.org/gpu/headless"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+)
+
+const (
+	// exportTileHeight is the height of the headless window that PNG
+	// exports are drawn through, a slice at a time.
+	exportTileHeight = 1024
+	// maxExportHeight bounds the height of PNG exports.
+	maxExportHeight = 1 << 15
+)
+
+// exportStyle is the style sheet of HTML exports.
+const exportStyle = `body { max-width: 48em; margin: 2em auto; padding: 0 1em; font-family: sans-serif; line-height: 1.5; }
+pre, code { background: #f6f8fa; font-family: monospace; }
+pre { padding: 0.5em; overflow: auto; }
+blockquote { margin-left: 0; padding-left: 1em; border-left: 4px solid #d0d7de; font-style: italic; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 0.25em 0.5em; }
+th { background: #f6f8fa; }
+img { max-width: 100%; }
+`
+
+// exportHTML converts src to a standalone HTML document.
+func exportHTML(r *Renderer, title string, src []byte) ([]byte, error) {
+	body, err := r.HTML(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n", html.EscapeString(title), exportStyle)
+	buf.Write(body)
+	buf.WriteString("</body>\n</html>\n")
+	return buf.Bytes(), nil
+}
+
+// exportPNG renders src as the preview shows it, width pixels wide,
+// and encodes the image as PNG. Relative image paths are relative to
+// the document at path.
+func exportPNG(r *Renderer, path string, src []byte, width int) ([]byte, error) {
+	if width <= 0 {
+		return nil, fmt.Errorf("invalid width %d", width)
+	}
+	blocks, err := r.Render(context.Background(), src)
+	if err != nil {
+		return nil, err
+	}
+	// The export has its own shaper and state, so that it can run
+	// alongside the window.
+	ui := &UI{
+		Theme:      NewTheme(gofont.Collection()),
+		Doc:        Document{Path: path},
+		TextState:  make([]BlockState, len(blocks)),
+		imageCache: make(map[string]*imageState),
+	}
+	ui.Theme.SetStyle(r.Style())
+	// There is no later frame to show images in, so load them first.
+	for _, b := range blocks {
+		for _, img := range b.Images {
+			key := ui.resolveImage(img.Dest)
+			if _, ok := ui.imageCache[key]; ok {
+				continue
+			}
+			m, err := loadImage(key)
+			s := &imageState{loaded: true, err: err}
+			if m != nil {
+				s.op = paint.NewImageOp(m)
+			}
+			ui.imageCache[key] = s
+		}
+	}
+	document := func(gtx C) D {
+		paint.Fill(gtx.Ops, ui.Theme.Base.Bg)
+		return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+			children := make([]layout.FlexChild, len(blocks))
+			for i := range blocks {
+				children[i] = layout.Rigid(func(gtx C) D {
+					return layout.Inset{Bottom: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+						return ui.layoutBlock(gtx, &blocks[i], &ui.TextState[i])
+					})
+				})
+			}
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+		})
+	}
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Metric:      unit.Metric{PxPerDp: 1, PxPerSp: 1},
+		Constraints: layout.Constraints{Min: image.Pt(width, 0), Max: image.Pt(width, maxExportHeight+1)},
+	}
+	// Measure the document before drawing it.
+	height := document(gtx).Size.Y
+	if height > maxExportHeight {
+		return nil, fmt.Errorf("document taller than %d pixels", maxExportHeight)
+	}
+	tile := min(height, exportTileHeight)
+	w, err := headless.NewWindow(width, tile)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Release()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	shot := image.NewRGBA(image.Rect(0, 0, width, tile))
+	for y := 0; y < height; y += tile {
+		gtx.Ops.Reset()
+		gtx.Constraints = layout.Constraints{Min: image.Pt(width, 0), Max: image.Pt(width, height)}
+		off := op.Offset(image.Pt(0, -y)).Push(gtx.Ops)
+		document(gtx)
+		off.Pop()
+		if err := w.Frame(gtx.Ops); err != nil {
+			return nil, err
+		}
+		if err := w.Screenshot(shot); err != nil {
+			return nil, err
+		}
+		draw.Draw(img, image.Rect(0, y, width, y+tile), shot, image.Point{}, draw.Src)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exportFormats lists the formats files can be exported to.
+var exportFormats = []string{"html", "png"}
+
+// convert exports each markdown file to the given formats, writing the
+// results next to the file, or to dir if set.
+func convert(r *Renderer, files, formats []string, dir string, width int) error {
+	for _, f := range formats {
+		if !slices.Contains(exportFormats, f) {
+			return fmt.Errorf("unknown export format %q", f)
+		}
+	}
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(path)
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		out := dir
+		if out == "" {
+			out = filepath.Dir(path)
+		}
+		for _, f := range formats {
+			var data []byte
+			switch f {
+			case "html":
+				data, err = exportHTML(r, base, src)
+			case "png":
+				data, err = exportPNG(r, path, src, width)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if err := os.WriteFile(filepath.Join(out, base+"."+f), data, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
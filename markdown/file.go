@@ -0,0 +1,408 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/io/key"
+	"gioui.org/io/system"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/explorer"
+)
+
+// autosaveDelay is the pause in editing after which a document with a
+// path is saved.
+const autosaveDelay = 2 * time.Second
+
+// Document tracks the file behind the editor.
+type Document struct {
+	// Path of the file, or empty for an untitled document.
+	Path string
+	// Dirty reports whether the editor has unsaved changes.
+	Dirty bool
+	// saved is the text as last opened or saved.
+	saved string
+	// edited is the time of the last change, for autosave.
+	edited time.Time
+	// saving is set while a save is in flight.
+	saving bool
+	// saveFailed is set when saving failed, to stop autosaving until
+	// the next edit rather than retrying the failure over and over.
+	saveFailed bool
+	// gen counts the documents opened, so that results of saving an
+	// earlier document are ignored.
+	gen int
+}
+
+// Name returns the file name of the document.
+func (d *Document) Name() string {
+	if d.Path == "" {
+		return "untitled.md"
+	}
+	return filepath.Base(d.Path)
+}
+
+// fileResult is the outcome of opening or saving a document in the
+// background.
+type fileResult struct {
+	// open is set for documents being opened.
+	open bool
+	// export is set for exported documents, which don't change the
+	// state of the editor.
+	export bool
+	gen    int
+	// path of the file, if known.
+	path string
+	text string
+	err  error
+}
+
+// FileBar holds the state of the file toolbar.
+type FileBar struct {
+	Open, Save, SaveAs widget.Clickable
+	ExportHTML         widget.Clickable
+	ExportPNG          widget.Clickable
+	// Width of exported PNG images, in pixels.
+	Width widget.Editor
+	// status briefly describes the last file operation.
+	status string
+	// confirm is the action waiting for the user to discard unsaved
+	// changes, if any.
+	confirm          func()
+	confirmText      string
+	discard, dismiss widget.Clickable
+}
+
+// Load replaces the document with text from path.
+func (ui *UI) Load(path, text string) {
+	ui.Doc = Document{Path: path, saved: text, gen: ui.Doc.gen + 1}
+	ui.Editor.SetText(text)
+	ui.requestRender(time.Time{})
+	ui.updateTitle()
+}
+
+// openFile reads a file chosen by the user.
+func (ui *UI) openFile() {
+	gen := ui.Doc.gen
+	go func() {
+		res := fileResult{open: true, gen: gen}
+		defer func() { ui.files <- res }()
+		f, err := ui.Explorer.ChooseFile("md", "markdown", "txt")
+		if err != nil {
+			res.err = err
+			return
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			res.err = fmt.Errorf("reading file: %w", err)
+			return
+		}
+		res.path, res.text = fileName(f), string(data)
+	}()
+}
+
+// saveFile saves the document to its path, asking the user for one if
+// it has none or if saveAs is set.
+func (ui *UI) saveFile(saveAs bool) {
+	ui.Doc.saving = true
+	path, text, gen := ui.Doc.Path, ui.Editor.Text(), ui.Doc.gen
+	name := ui.Doc.Name()
+	go func() {
+		res := fileResult{gen: gen, path: path, text: text}
+		defer func() { ui.files <- res }()
+		if path != "" && !saveAs {
+			res.err = os.WriteFile(path, []byte(text), 0o644)
+			return
+		}
+		f, err := ui.Explorer.CreateFile(name)
+		if err != nil {
+			res.err = err
+			return
+		}
+		// Without a path, the next save asks for a file again.
+		res.path = fileName(f)
+		if _, err := io.WriteString(f, text); err != nil {
+			f.Close()
+			res.err = fmt.Errorf("writing file: %w", err)
+			return
+		}
+		res.err = f.Close()
+	}()
+}
+
+// exportFile writes the document in the given format to a file chosen
+// by the user.
+func (ui *UI) exportFile(format string) {
+	text, path := ui.Editor.Text(), ui.Doc.Path
+	base := strings.TrimSuffix(ui.Doc.Name(), filepath.Ext(ui.Doc.Name()))
+	name := base + "." + format
+	width, err := strconv.Atoi(ui.Files.Width.Text())
+	if err != nil {
+		ui.Notifications.Push("Exporting: invalid width " + strconv.Quote(ui.Files.Width.Text()))
+		return
+	}
+	go func() {
+		res := fileResult{export: true, path: name}
+		defer func() { ui.files <- res }()
+		var data []byte
+		switch format {
+		case "html":
+			data, res.err = exportHTML(ui.Renderer, base, []byte(text))
+		case "png":
+			data, res.err = exportPNG(ui.Renderer, path, []byte(text), width)
+		}
+		if res.err != nil {
+			return
+		}
+		f, err := ui.Explorer.CreateFile(name)
+		if err != nil {
+			res.err = err
+			return
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			res.err = fmt.Errorf("writing file: %w", err)
+			return
+		}
+		if p := fileName(f); p != "" {
+			res.path = p
+		}
+		res.err = f.Close()
+	}()
+}
+
+// fileName returns the path of f, if f is a file on disk.
+func fileName(f any) string {
+	if f, ok := f.(*os.File); ok {
+		return f.Name()
+	}
+	return ""
+}
+
+// fileDone applies the result of opening or saving a document.
+func (ui *UI) fileDone(res fileResult) {
+	if res.export {
+		switch {
+		case errors.Is(res.err, explorer.ErrUserDecline):
+		case res.err != nil:
+			ui.Notifications.Push("Exporting: " + res.err.Error())
+		default:
+			ui.Files.status = "Exported " + filepath.Base(res.path)
+		}
+		return
+	}
+	if !res.open {
+		if res.gen != ui.Doc.gen {
+			return
+		}
+		ui.Doc.saving = false
+		ui.Doc.saveFailed = res.err != nil && !errors.Is(res.err, explorer.ErrUserDecline)
+	}
+	switch {
+	case errors.Is(res.err, explorer.ErrUserDecline):
+		// Changes discarded for a file that wasn't opened after all
+		// are unsaved again.
+		ui.Doc.Dirty = ui.Editor.Text() != ui.Doc.saved
+	case res.err != nil:
+		ui.Notifications.Push(res.err.Error())
+	case res.open:
+		ui.Load(res.path, res.text)
+		ui.Files.status = "Opened " + ui.Doc.Name()
+	default:
+		if res.path != "" {
+			ui.Doc.Path = res.path
+		}
+		ui.Doc.saved = res.text
+		ui.Doc.Dirty = ui.Editor.Text() != res.text
+		ui.Files.status = "Saved " + ui.Doc.Name()
+	}
+	ui.updateTitle()
+}
+
+// SaveOnExit saves unsaved changes as the window closes. Closing the
+// window can't be cancelled, so untitled documents are written to a
+// recovery file instead of being lost.
+func (ui *UI) SaveOnExit() {
+	if !ui.Doc.Dirty {
+		return
+	}
+	path := ui.Doc.Path
+	if path == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			log.Printf("error: unsaved changes lost: %v", err)
+			return
+		}
+		dir = filepath.Join(dir, "gio-markdown")
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			log.Printf("error: unsaved changes lost: %v", err)
+			return
+		}
+		path = filepath.Join(dir, "recovered.md")
+	}
+	if err := os.WriteFile(path, []byte(ui.Editor.Text()), 0o644); err != nil {
+		log.Printf("error: unsaved changes lost: %v", err)
+		return
+	}
+	if ui.Doc.Path == "" {
+		log.Printf("unsaved changes written to %s", path)
+	}
+}
+
+// updateTitle shows the document name in the window title, marked
+// with an asterisk while there are unsaved changes.
+func (ui *UI) updateTitle() {
+	title := ui.Doc.Name() + " - Markdown"
+	if ui.Doc.Dirty {
+		title = "*" + title
+	}
+	if title != ui.title {
+		ui.title = title
+		ui.Window.Option(app.Title(title))
+	}
+}
+
+// confirmDiscard runs action once the user agrees to lose unsaved
+// changes, or right away if there are none. Documents with a path have
+// them saved first.
+func (ui *UI) confirmDiscard(question string, action func()) {
+	switch {
+	case !ui.Doc.Dirty:
+		action()
+	case ui.Doc.Path != "":
+		ui.saveFile(false)
+		action()
+	default:
+		ui.Files.confirm = action
+		ui.Files.confirmText = question
+	}
+}
+
+// updateFile handles the file toolbar, its shortcuts and autosave.
+func (ui *UI) updateFile(gtx C) {
+	f := &ui.Files
+	open, save, saveAs, quit := f.Open.Clicked(gtx), f.Save.Clicked(gtx), f.SaveAs.Clicked(gtx), false
+	for {
+		ev, ok := gtx.Event(
+			key.Filter{Required: key.ModShortcut, Name: "O"},
+			key.Filter{Required: key.ModShortcut, Optional: key.ModShift, Name: "S"},
+			key.Filter{Required: key.ModShortcut, Name: "Q"},
+		)
+		if !ok {
+			break
+		}
+		e, ok := ev.(key.Event)
+		if !ok || e.State != key.Press {
+			continue
+		}
+		switch e.Name {
+		case "O":
+			open = true
+		case "S":
+			if e.Modifiers.Contain(key.ModShift) {
+				saveAs = true
+			} else {
+				save = true
+			}
+		case "Q":
+			quit = true
+		}
+	}
+	if f.ExportHTML.Clicked(gtx) {
+		ui.exportFile("html")
+	}
+	if f.ExportPNG.Clicked(gtx) {
+		ui.exportFile("png")
+	}
+	if f.confirm != nil {
+		if f.discard.Clicked(gtx) {
+			action := f.confirm
+			f.confirm = nil
+			ui.Doc.Dirty = false
+			action()
+		}
+		if f.dismiss.Clicked(gtx) {
+			f.confirm = nil
+		}
+	}
+	switch {
+	case open:
+		ui.confirmDiscard("Discard unsaved changes and open another file?", ui.openFile)
+	case save, saveAs:
+		if !ui.Doc.saving {
+			ui.saveFile(saveAs)
+		}
+	case quit:
+		ui.confirmDiscard("Discard unsaved changes and quit?", func() {
+			ui.Window.Perform(system.ActionClose)
+		})
+	}
+	if ui.Doc.Dirty && ui.Doc.Path != "" && !ui.Doc.saving && !ui.Doc.saveFailed {
+		if at := ui.Doc.edited.Add(autosaveDelay); gtx.Now.Before(at) {
+			gtx.Execute(op.InvalidateCmd{At: at})
+		} else {
+			ui.saveFile(false)
+		}
+	}
+}
+
+// layoutFileBar lays out the file toolbar and the question about
+// unsaved changes, if any.
+func (ui *UI) layoutFileBar(gtx C) D {
+	f := &ui.Files
+	th := ui.Theme.Base
+	button := func(c *widget.Clickable, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return layout.Inset{Right: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+				btn := material.Button(th, c, label)
+				btn.TextSize = unit.Sp(12)
+				btn.Inset = layout.UniformInset(unit.Dp(6))
+				return btn.Layout(gtx)
+			})
+		})
+	}
+	status := f.status
+	if f.confirm != nil {
+		status = f.confirmText
+	}
+	children := []layout.FlexChild{
+		button(&ui.Outline.Toggle, "Outline"),
+		button(&ui.Styles.Switch, ui.Theme.Style.Name),
+		button(&f.Open, "Open"),
+		button(&f.Save, "Save"),
+		button(&f.SaveAs, "Save As"),
+		button(&f.ExportHTML, "Export HTML"),
+		button(&f.ExportPNG, "Export PNG"),
+		layout.Rigid(func(gtx C) D {
+			gtx.Constraints.Min.X = gtx.Dp(unit.Dp(48))
+			gtx.Constraints.Max.X = gtx.Constraints.Min.X
+			ed := material.Editor(th, &f.Width, "width")
+			ed.TextSize = unit.Sp(12)
+			return ed.Layout(gtx)
+		}),
+		layout.Flexed(1, func(gtx C) D {
+			return layout.Inset{Left: unit.Dp(4)}.Layout(gtx, material.Caption(th, status).Layout)
+		}),
+	}
+	if f.confirm != nil {
+		children = append(children, button(&f.discard, "Discard"), button(&f.dismiss, "Cancel"))
+	}
+	return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+		return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+	})
+}
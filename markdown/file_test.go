@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+func TestAutosaveFailure(t *testing.T) {
+	ui := newTestUI()
+	// The directory of the document doesn't exist, so saving fails.
+	path := filepath.Join(t.TempDir(), "missing", "doc.md")
+	ui.Load(path, "text")
+	now := time.Now()
+	gtx := layout.Context{Ops: new(op.Ops), Now: now, Constraints: layout.Exact(image.Pt(800, 600))}
+	ui.changed(gtx)
+	gtx.Now = now.Add(autosaveDelay)
+	ui.updateFile(gtx)
+	if !ui.Doc.saving {
+		t.Fatal("no autosave after the delay")
+	}
+	ui.fileDone(<-ui.files)
+	if !ui.Doc.Dirty {
+		t.Error("document clean after a failed save")
+	}
+	gtx.Now = gtx.Now.Add(autosaveDelay)
+	ui.updateFile(gtx)
+	if ui.Doc.saving {
+		t.Fatal("autosave retried a failed save without further edits")
+	}
+
+	// The next edit autosaves again, and succeeds once the directory
+	// exists.
+	if err := os.Mkdir(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ui.changed(gtx)
+	gtx.Now = gtx.Now.Add(autosaveDelay)
+	ui.updateFile(gtx)
+	if !ui.Doc.saving {
+		t.Fatal("no autosave after an edit")
+	}
+	ui.fileDone(<-ui.files)
+	if ui.Doc.saveFailed {
+		t.Error("save failed with the directory in place")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error(err)
+	}
+}
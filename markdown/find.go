@@ -0,0 +1,496 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/font"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"gioui.org/x/richtext"
+)
+
+// maxMatches bounds the matches found, to keep highlighting them cheap.
+const maxMatches = 10000
+
+var (
+	// matchHighlight is painted under the matches in the editor.
+	matchHighlight = color.NRGBA{R: 0xff, G: 0xd5, B: 0x4f, A: 0x90}
+	// matchColor is the color of the matches in the preview.
+	matchColor = color.NRGBA{R: 0xe6, G: 0x51, B: 0x00, A: 0xff}
+)
+
+// FindBar holds the state of the find and replace bar.
+type FindBar struct {
+	// Visible reports whether the bar is shown, and Replace whether it
+	// includes the replacement field.
+	Visible, Replace   bool
+	Query, Replacement widget.Editor
+	MatchCase, Regexp  widget.Bool
+	Next, Prev, Close  widget.Clickable
+	ReplaceOne         widget.Clickable
+	ReplaceAll         widget.Clickable
+
+	// stale is set when the matches are to be found again.
+	stale bool
+	// re matches the query, or is nil if there is none.
+	re  *regexp.Regexp
+	err error
+	// matches are the matches in the editor text.
+	matches []match
+	// blocks are the rendered blocks with the matches highlighted.
+	blocks  []Block
+	regions []widget.Region
+}
+
+// match is a match of the query in the editor text.
+type match struct {
+	// start and end are rune offsets.
+	start, end int
+	// loc holds the byte offsets of the match and its submatches, for
+	// expanding the replacement.
+	loc []int
+}
+
+// active reports whether matches are to be highlighted.
+func (f *FindBar) active() bool {
+	return f.Visible && f.re != nil
+}
+
+// compile compiles the query into f.re.
+func (f *FindBar) compile() {
+	f.re, f.err = nil, nil
+	q := f.Query.Text()
+	if q == "" {
+		return
+	}
+	if !f.Regexp.Value {
+		q = regexp.QuoteMeta(q)
+	}
+	if !f.MatchCase.Value {
+		q = "(?i)" + q
+	}
+	f.re, f.err = regexp.Compile(q)
+}
+
+// find finds the matches of the query in the editor text and in the
+// rendered blocks.
+func (ui *UI) find() {
+	f := &ui.Find
+	f.stale = false
+	f.compile()
+	f.matches = f.matches[:0]
+	f.blocks = nil
+	if f.re == nil {
+		return
+	}
+	text := ui.Editor.Text()
+	runes, offset := 0, 0
+	for _, loc := range f.re.FindAllStringSubmatchIndex(text, maxMatches) {
+		if loc[0] == loc[1] {
+			// Empty matches can't be seen nor replaced.
+			continue
+		}
+		start := runes + utf8.RuneCountInString(text[offset:loc[0]])
+		end := start + utf8.RuneCountInString(text[loc[0]:loc[1]])
+		runes, offset = end, loc[1]
+		f.matches = append(f.matches, match{start: start, end: end, loc: loc})
+	}
+	f.blocks = make([]Block, len(ui.Theme.cache))
+	for i, b := range ui.Theme.cache {
+		f.blocks[i] = highlightBlock(b, f.re)
+	}
+}
+
+// highlightBlock returns b with the matches of re in its text in the
+// match color.
+func highlightBlock(b Block, re *regexp.Regexp) Block {
+	if b.Table != nil {
+		t := *b.Table
+		t.Rows = make([][][]richtext.SpanStyle, len(b.Table.Rows))
+		for i, row := range b.Table.Rows {
+			t.Rows[i] = make([][]richtext.SpanStyle, len(row))
+			for j, cell := range row {
+				t.Rows[i][j], _ = highlightSpans(cell, re)
+			}
+		}
+		b.Table = &t
+	}
+	var index []int
+	b.Spans, index = highlightSpans(b.Spans, re)
+	if len(b.Images) > 0 {
+		images := make([]Image, len(b.Images))
+		for i, img := range b.Images {
+			img.At = index[img.At]
+			images[i] = img
+		}
+		b.Images = images
+	}
+	return b
+}
+
+// highlightSpans splits spans at the boundaries of the matches of re in
+// their text, and colors the matching parts. index maps the index of
+// each span, and len(spans), to the index of its first part.
+func highlightSpans(spans []richtext.SpanStyle, re *regexp.Regexp) (parts []richtext.SpanStyle, index []int) {
+	var text strings.Builder
+	for _, s := range spans {
+		text.WriteString(s.Content)
+	}
+	locs := re.FindAllStringIndex(text.String(), maxMatches)
+	index = make([]int, len(spans)+1)
+	offset := 0
+	for i, s := range spans {
+		index[i] = len(parts)
+		// start and end are byte offsets in the text.
+		base := offset
+		start, end := base, base+len(s.Content)
+		offset = end
+		for start < end {
+			// Skip the matches before start, and find the part of the
+			// span up to the next match boundary.
+			for len(locs) > 0 && locs[0][1] <= start {
+				locs = locs[1:]
+			}
+			cut, inside := end, false
+			if len(locs) > 0 {
+				if loc := locs[0]; loc[0] <= start {
+					cut, inside = min(loc[1], end), true
+				} else {
+					cut = min(loc[0], end)
+				}
+			}
+			part := s
+			part.Content = s.Content[start-base : cut-base]
+			if inside {
+				part.Color = matchColor
+				part.Font.Weight = font.Bold
+			}
+			parts = append(parts, part)
+			start = cut
+		}
+	}
+	index[len(spans)] = len(parts)
+	return parts, index
+}
+
+// selection returns the editor selection, start first.
+func (ui *UI) selection() (start, end int) {
+	start, end = ui.Editor.Selection()
+	return min(start, end), max(start, end)
+}
+
+// findNext selects the next match after the selection, or the previous
+// one before it, wrapping around the document.
+func (ui *UI) findNext(forward bool) {
+	matches := ui.Find.matches
+	if len(matches) == 0 {
+		return
+	}
+	start, end := ui.selection()
+	var m match
+	if forward {
+		m = matches[0]
+		for _, mm := range matches {
+			if mm.start >= end {
+				m = mm
+				break
+			}
+		}
+	} else {
+		m = matches[len(matches)-1]
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i].end <= start {
+				m = matches[i]
+				break
+			}
+		}
+	}
+	ui.Editor.SetCaret(m.end, m.start)
+}
+
+// current returns the index of the match that is selected, if any.
+func (ui *UI) current() (int, bool) {
+	start, end := ui.selection()
+	for i, m := range ui.Find.matches {
+		if m.start == start && m.end == end {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// replacement returns the replacement for m in text.
+func (f *FindBar) replacement(text string, m match) string {
+	if !f.Regexp.Value {
+		return f.Replacement.Text()
+	}
+	return string(f.re.ExpandString(nil, f.Replacement.Text(), text, m.loc))
+}
+
+// replace replaces the selected match and selects the next one, or
+// just selects the next match if none is selected.
+func (ui *UI) replace(gtx C) {
+	f := &ui.Find
+	i, ok := ui.current()
+	if !ok {
+		ui.findNext(true)
+		return
+	}
+	m := f.matches[i]
+	ui.Editor.Insert(f.replacement(ui.Editor.Text(), m))
+	ui.changed(gtx)
+	ui.find()
+	ui.findNext(true)
+}
+
+// replaceAll replaces every match.
+func (ui *UI) replaceAll(gtx C) {
+	f := &ui.Find
+	if f.re == nil {
+		return
+	}
+	text, n := f.replaceAllIn(ui.Editor.Text())
+	if n == 0 {
+		return
+	}
+	caret, _ := ui.Editor.Selection()
+	// Replace the text as a single edit, so that it can be undone.
+	ui.Editor.SetCaret(0, ui.Editor.Len())
+	end := ui.Editor.Insert(text)
+	ui.Editor.SetCaret(min(caret, end), min(caret, end))
+	ui.changed(gtx)
+	ui.Files.status = fmt.Sprintf("Replaced %d matches", n)
+	ui.find()
+}
+
+// replaceAllIn returns text with every match replaced, and the number
+// of matches replaced. Unlike the matches found for highlighting, the
+// matches replaced are not bounded by maxMatches.
+func (f *FindBar) replaceAllIn(text string) (string, int) {
+	var b strings.Builder
+	n, offset := 0, 0
+	for _, loc := range f.re.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		b.WriteString(text[offset:loc[0]])
+		b.WriteString(f.replacement(text, match{loc: loc}))
+		offset = loc[1]
+		n++
+	}
+	b.WriteString(text[offset:])
+	return b.String(), n
+}
+
+// openFind shows the find bar, with the replacement field if replace
+// is set.
+func (ui *UI) openFind(gtx C, replace bool) {
+	f := &ui.Find
+	f.Visible, f.Replace, f.stale = true, replace, true
+	f.Query.SingleLine, f.Query.Submit = true, true
+	f.Replacement.SingleLine, f.Replacement.Submit = true, true
+	if sel := ui.Editor.SelectedText(); sel != "" && !strings.Contains(sel, "\n") {
+		f.Query.SetText(sel)
+	}
+	f.Query.SetCaret(f.Query.Len(), 0)
+	gtx.Execute(key.FocusCmd{Tag: &f.Query})
+}
+
+// updateFind handles the find bar and its shortcuts.
+func (ui *UI) updateFind(gtx C) {
+	f := &ui.Find
+	for {
+		ev, ok := gtx.Event(
+			key.Filter{Required: key.ModShortcut, Name: "F"},
+			key.Filter{Required: key.ModShortcut, Name: "H"},
+			key.Filter{Name: key.NameEscape},
+		)
+		if !ok {
+			break
+		}
+		e, ok := ev.(key.Event)
+		if !ok || e.State != key.Press {
+			continue
+		}
+		switch e.Name {
+		case "F":
+			ui.openFind(gtx, false)
+		case "H":
+			ui.openFind(gtx, true)
+		case key.NameEscape:
+			if f.Visible {
+				f.Visible = false
+				gtx.Execute(key.FocusCmd{Tag: &ui.Editor})
+			}
+		}
+	}
+	if !f.Visible {
+		return
+	}
+	for {
+		ev, ok := f.Query.Update(gtx)
+		if !ok {
+			break
+		}
+		switch ev.(type) {
+		case widget.ChangeEvent:
+			f.stale = true
+		case widget.SubmitEvent:
+			ui.findNext(true)
+		}
+	}
+	for {
+		ev, ok := f.Replacement.Update(gtx)
+		if !ok {
+			break
+		}
+		if _, ok := ev.(widget.SubmitEvent); ok {
+			ui.replace(gtx)
+		}
+	}
+	if f.MatchCase.Update(gtx) || f.Regexp.Update(gtx) {
+		f.stale = true
+	}
+	if f.stale {
+		ui.find()
+	}
+	switch {
+	case f.Next.Clicked(gtx):
+		ui.findNext(true)
+	case f.Prev.Clicked(gtx):
+		ui.findNext(false)
+	case f.ReplaceOne.Clicked(gtx):
+		ui.replace(gtx)
+	case f.ReplaceAll.Clicked(gtx):
+		ui.replaceAll(gtx)
+	case f.Close.Clicked(gtx):
+		f.Visible = false
+	}
+}
+
+// layoutEditor lays out the editor, over the highlighted matches.
+func (ui *UI) layoutEditor(gtx C) D {
+	f := &ui.Find
+	ed := material.Editor(ui.Theme.Base, &ui.Editor, "markdown")
+	if !f.active() {
+		return ed.Layout(gtx)
+	}
+	m := op.Record(gtx.Ops)
+	dims := ed.Layout(gtx)
+	call := m.Stop()
+	for _, mm := range f.matches {
+		f.regions = ui.Editor.Regions(mm.start, mm.end, f.regions[:0])
+		for _, r := range f.regions {
+			paint.FillShape(gtx.Ops, matchHighlight, clip.Rect(r.Bounds).Op())
+		}
+	}
+	call.Add(gtx.Ops)
+	return dims
+}
+
+// previewBlocks returns the blocks to show in the preview, with the
+// matches highlighted while finding.
+func (ui *UI) previewBlocks() []Block {
+	if f := &ui.Find; f.active() && len(f.blocks) == len(ui.Theme.cache) {
+		return f.blocks
+	}
+	return ui.Theme.cache
+}
+
+// layoutFindBar lays out the find bar, if visible.
+func (ui *UI) layoutFindBar(gtx C) D {
+	f := &ui.Find
+	if !f.Visible {
+		return D{}
+	}
+	th := ui.Theme.Base
+	field := func(e *widget.Editor, hint string) layout.FlexChild {
+		return layout.Flexed(1, func(gtx C) D {
+			return layout.Inset{Right: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+				border := widget.Border{Color: color.NRGBA(ui.Theme.Style.Border), CornerRadius: unit.Dp(2), Width: unit.Dp(1)}
+				return border.Layout(gtx, func(gtx C) D {
+					return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+						ed := material.Editor(th, e, hint)
+						ed.TextSize = unit.Sp(12)
+						return ed.Layout(gtx)
+					})
+				})
+			})
+		})
+	}
+	button := func(c *widget.Clickable, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			return layout.Inset{Right: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+				btn := material.Button(th, c, label)
+				btn.TextSize = unit.Sp(12)
+				btn.Inset = layout.UniformInset(unit.Dp(6))
+				return btn.Layout(gtx)
+			})
+		})
+	}
+	toggle := func(b *widget.Bool, label string) layout.FlexChild {
+		return layout.Rigid(func(gtx C) D {
+			cb := material.CheckBox(th, b, label)
+			cb.TextSize = unit.Sp(12)
+			cb.Size = unit.Dp(16)
+			return cb.Layout(gtx)
+		})
+	}
+	var status string
+	switch {
+	case f.err != nil:
+		status = "Invalid pattern"
+	case f.re == nil:
+	case len(f.matches) == 0:
+		status = "No matches"
+	default:
+		if i, ok := ui.current(); ok {
+			status = fmt.Sprintf("%d of %d", i+1, len(f.matches))
+		} else {
+			status = fmt.Sprintf("%d matches", len(f.matches))
+		}
+	}
+	rows := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+				field(&f.Query, "Find"),
+				toggle(&f.MatchCase, "Match case"),
+				toggle(&f.Regexp, "Regexp"),
+				layout.Rigid(func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(88))
+					return layout.Inset{Left: unit.Dp(8), Right: unit.Dp(4)}.Layout(gtx, material.Caption(th, status).Layout)
+				}),
+				button(&f.Prev, "Previous"),
+				button(&f.Next, "Next"),
+				button(&f.Close, "×"),
+			)
+		}),
+	}
+	if f.Replace {
+		rows = append(rows, layout.Rigid(func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					field(&f.Replacement, "Replace"),
+					button(&f.ReplaceOne, "Replace"),
+					button(&f.ReplaceAll, "Replace all"),
+				)
+			})
+		}))
+	}
+	return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+		gtx.Constraints.Min.X = gtx.Constraints.Max.X
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+	})
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"gioui.org/x/richtext"
+)
+
+func TestHighlightSpans(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans []string
+		re    string
+		// parts are the contents of the parts, with matching parts in
+		// brackets.
+		parts []string
+		index []int
+	}{
+		{
+			name:  "no match",
+			spans: []string{"abc", "def"},
+			re:    "x",
+			parts: []string{"abc", "def"},
+			index: []int{0, 1, 2},
+		},
+		{
+			name:  "within a span",
+			spans: []string{"say hello there"},
+			re:    "hello",
+			parts: []string{"say ", "[hello]", " there"},
+			index: []int{0, 3},
+		},
+		{
+			name:  "across spans",
+			spans: []string{"hel", "lo", " world"},
+			re:    "hello w",
+			parts: []string{"[hel]", "[lo]", "[ w]", "orld"},
+			index: []int{0, 1, 2, 4},
+		},
+		{
+			name:  "several in a span",
+			spans: []string{"a", "xbx", ""},
+			re:    "x",
+			parts: []string{"a", "[x]", "b", "[x]"},
+			index: []int{0, 1, 4, 4},
+		},
+		{
+			name:  "image between spans",
+			spans: []string{"ab", "", "ba"},
+			re:    "ba|b",
+			parts: []string{"a", "[b]", "[ba]"},
+			index: []int{0, 2, 2, 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spans := make([]richtext.SpanStyle, len(test.spans))
+			for i, s := range test.spans {
+				spans[i].Content = s
+			}
+			parts, index := highlightSpans(spans, regexp.MustCompile(test.re))
+			var got []string
+			for _, p := range parts {
+				if p.Color == matchColor {
+					got = append(got, "["+p.Content+"]")
+				} else {
+					got = append(got, p.Content)
+				}
+			}
+			if !slices.Equal(got, test.parts) {
+				t.Errorf("got parts %q, want %q", got, test.parts)
+			}
+			if !slices.Equal(index, test.index) {
+				t.Errorf("got index %v, want %v", index, test.index)
+			}
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		name              string
+		query, repl       string
+		regexp, matchCase bool
+		text              string
+		want              string
+		replacements      int
+		// first is the replacement of the first match, if not empty.
+		first string
+	}{
+		{
+			name:         "literal",
+			query:        "a.b",
+			repl:         "$1",
+			text:         "a.b axb A.B",
+			want:         "$1 axb $1",
+			replacements: 2,
+			first:        "$1",
+		},
+		{
+			name:         "match case",
+			query:        "a.b",
+			repl:         "c",
+			matchCase:    true,
+			text:         "a.b A.B",
+			want:         "c A.B",
+			replacements: 1,
+			first:        "c",
+		},
+		{
+			name:         "submatches",
+			query:        `(\w+)@(\w+)`,
+			repl:         "$2 at $1",
+			regexp:       true,
+			text:         "bob@home, alice@work",
+			want:         "home at bob, work at alice",
+			replacements: 2,
+			first:        "home at bob",
+		},
+		{
+			name:         "named submatch",
+			query:        `(?P<word>\w+)!`,
+			repl:         "${word}?",
+			regexp:       true,
+			text:         "hey! you!",
+			want:         "hey? you?",
+			replacements: 2,
+			first:        "hey?",
+		},
+		{
+			name:         "empty matches",
+			query:        `x*`,
+			repl:         "-",
+			regexp:       true,
+			text:         "axxb",
+			want:         "a-b",
+			replacements: 1,
+		},
+		{
+			name:         "more than maxMatches",
+			query:        "a",
+			repl:         "b",
+			text:         strings.Repeat("a", maxMatches+5),
+			want:         strings.Repeat("b", maxMatches+5),
+			replacements: maxMatches + 5,
+			first:        "b",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var f FindBar
+			f.Query.SetText(test.query)
+			f.Replacement.SetText(test.repl)
+			f.Regexp.Value = test.regexp
+			f.MatchCase.Value = test.matchCase
+			f.compile()
+			if f.err != nil {
+				t.Fatal(f.err)
+			}
+			got, n := f.replaceAllIn(test.text)
+			if got != test.want || n != test.replacements {
+				t.Errorf("got %q with %d replacements, want %q with %d", abbrev(got), n, abbrev(test.want), test.replacements)
+			}
+			if test.first != "" {
+				m := match{loc: f.re.FindStringSubmatchIndex(test.text)}
+				if got := f.replacement(test.text, m); got != test.first {
+					t.Errorf("got replacement %q for the first match, want %q", got, test.first)
+				}
+			}
+		})
+	}
+}
+
+// abbrev shortens long strings for test failures.
+func abbrev(s string) string {
+	if len(s) > 40 {
+		return fmt.Sprintf("%s… (%d bytes)", s[:40], len(s))
+	}
+	return s
+}
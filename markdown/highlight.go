@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind classifies the tokens of highlighted code.
+type tokenKind uint8
+
+const (
+	plainToken tokenKind = iota
+	keywordToken
+	stringToken
+	commentToken
+	numberToken
+)
+
+// language describes the lexical syntax of a programming language, just
+// enough to highlight it.
+type language struct {
+	keywords      map[string]bool
+	lineComments  []string
+	blockComments [][2]string
+	// quotes are the string delimiters.
+	quotes string
+}
+
+func newLanguage(keywords string, lineComments []string, blockComments [][2]string, quotes string) *language {
+	l := &language{
+		keywords:      make(map[string]bool),
+		lineComments:  lineComments,
+		blockComments: blockComments,
+		quotes:        quotes,
+	}
+	for _, k := range strings.Fields(keywords) {
+		l.keywords[k] = true
+	}
+	return l
+}
+
+var (
+	cStyle     = [][2]string{{"/*", "*/"}}
+	goLang     = newLanguage("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota", []string{"//"}, cStyle, "\"'`")
+	jsLang     = newLanguage("break case catch class const continue debugger default delete do else export extends finally for function if import in instanceof let new of return super switch this throw try typeof var void while with yield async await null undefined true false interface type enum implements", []string{"//"}, cStyle, "\"'`")
+	pythonLang = newLanguage("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self", []string{"#"}, nil, "\"'")
+	shellLang  = newLanguage("if then else elif fi case esac for while until do done in function return local export echo exit", []string{"#"}, nil, "\"'")
+	cLang      = newLanguage("auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while class namespace template typename public private protected virtual new delete this true false nullptr bool", []string{"//"}, cStyle, "\"'")
+	rustLang   = newLanguage("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while", []string{"//"}, cStyle, "\"")
+	jsonLang   = newLanguage("true false null", nil, nil, "\"")
+)
+
+// languages maps the info string of fenced code blocks to languages.
+var languages = map[string]*language{
+	"go":         goLang,
+	"golang":     goLang,
+	"js":         jsLang,
+	"javascript": jsLang,
+	"ts":         jsLang,
+	"typescript": jsLang,
+	"py":         pythonLang,
+	"python":     pythonLang,
+	"sh":         shellLang,
+	"bash":       shellLang,
+	"shell":      shellLang,
+	"c":          cLang,
+	"cpp":        cLang,
+	"c++":        cLang,
+	"java":       cLang,
+	"rust":       rustLang,
+	"rs":         rustLang,
+	"json":       jsonLang,
+}
+
+// highlight splits code into tokens of the named language, calling
+// yield for each. Code in unknown languages is a single plain token.
+func highlight(lang, code string, yield func(kind tokenKind, tok string)) {
+	l := languages[strings.ToLower(lang)]
+	if l == nil {
+		yield(plainToken, code)
+		return
+	}
+	// plain accumulates plain text, so that runs of it are yielded as
+	// single tokens.
+	plain := 0
+	flush := func(i int) {
+		if plain < i {
+			yield(plainToken, code[plain:i])
+		}
+	}
+	emit := func(kind tokenKind, start, end int) int {
+		flush(start)
+		yield(kind, code[start:end])
+		plain = end
+		return end
+	}
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		if end, ok := l.comment(rest); ok {
+			i = emit(commentToken, i, i+end)
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(rest)
+		switch {
+		case strings.ContainsRune(l.quotes, r):
+			i = emit(stringToken, i, i+quoted(rest, r))
+		case unicode.IsDigit(r):
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '_'
+			})
+			if end == -1 {
+				end = len(rest)
+			}
+			i = emit(numberToken, i, i+end)
+		case unicode.IsLetter(r) || r == '_':
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+			})
+			if end == -1 {
+				end = len(rest)
+			}
+			if l.keywords[rest[:end]] {
+				i = emit(keywordToken, i, i+end)
+			} else {
+				i += end
+			}
+		default:
+			i += n
+		}
+	}
+	flush(len(code))
+}
+
+// comment returns the length of the comment at the start of s, if any.
+func (l *language) comment(s string) (int, bool) {
+	for _, c := range l.lineComments {
+		if strings.HasPrefix(s, c) {
+			if end := strings.IndexByte(s, '\n'); end != -1 {
+				return end, true
+			}
+			return len(s), true
+		}
+	}
+	for _, c := range l.blockComments {
+		if strings.HasPrefix(s, c[0]) {
+			if end := strings.Index(s[len(c[0]):], c[1]); end != -1 {
+				return len(c[0]) + end + len(c[1]), true
+			}
+			return len(s), true
+		}
+	}
+	return 0, false
+}
+
+// quoted returns the length of the string at the start of s, delimited
+// by q. Strings end at the end of the line, except raw strings quoted by
+// backticks.
+func quoted(s string, q rune) int {
+	escaped := false
+	for i, r := range s[1:] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && q != '`':
+			escaped = true
+		case r == q:
+			return i + 2
+		case r == '\n' && q != '`':
+			return i + 1
+		}
+	}
+	return len(s)
+}
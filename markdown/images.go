@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+const (
+	// maxImageBytes bounds the size of image files.
+	maxImageBytes = 32 << 20
+	// maxImagePixels bounds the size of decoded images, which take 4
+	// bytes per pixel.
+	maxImagePixels = 4096 * 4096
+)
+
+// errRemoteImage is the error of images at http(s) URLs, which are
+// not fetched: opening a document must not make network requests.
+var errRemoteImage = errors.New("remote images are not loaded")
+
+// imageState is an image in the cache, loaded or not.
+type imageState struct {
+	loaded bool
+	op     paint.ImageOp
+	err    error
+}
+
+// imageResult is the outcome of loading an image.
+type imageResult struct {
+	key string
+	img image.Image
+	err error
+}
+
+// resolveImage returns the URL or absolute path of the image at dest.
+// Relative paths are relative to the directory of the document.
+func (ui *UI) resolveImage(dest string) string {
+	if isRemote(dest) {
+		return dest
+	}
+	dest = filepath.FromSlash(strings.TrimPrefix(dest, "file://"))
+	if filepath.IsAbs(dest) {
+		return dest
+	}
+	dir := "."
+	if ui.Doc.Path != "" {
+		dir = filepath.Dir(ui.Doc.Path)
+	}
+	if path, err := filepath.Abs(filepath.Join(dir, dest)); err == nil {
+		return path
+	}
+	return dest
+}
+
+// image returns the image with the given key, starting to load it if
+// it's not in the cache.
+func (ui *UI) image(key string) *imageState {
+	if s, ok := ui.imageCache[key]; ok {
+		return s
+	}
+	if ui.imageCache == nil {
+		ui.imageCache = make(map[string]*imageState)
+	}
+	s := new(imageState)
+	ui.imageCache[key] = s
+	if isRemote(key) {
+		s.loaded = true
+		s.err = errRemoteImage
+		return s
+	}
+	go func() {
+		img, err := loadImage(key)
+		ui.imageResults <- imageResult{key: key, img: img, err: err}
+	}()
+	return s
+}
+
+// imageDone stores a loaded image in the cache.
+func (ui *UI) imageDone(res imageResult) {
+	s, ok := ui.imageCache[res.key]
+	if !ok {
+		// The image is no longer in the document.
+		return
+	}
+	s.loaded = true
+	s.err = res.err
+	if res.img != nil {
+		s.op = paint.NewImageOp(res.img)
+	}
+}
+
+// pruneImages drops the images no longer in the document from the
+// cache.
+func (ui *UI) pruneImages() {
+	used := make(map[string]bool)
+	for _, b := range ui.Theme.cache {
+		for _, img := range b.Images {
+			used[ui.resolveImage(img.Dest)] = true
+		}
+	}
+	for key := range ui.imageCache {
+		if !used[key] {
+			delete(ui.imageCache, key)
+		}
+	}
+}
+
+// isRemote reports whether dest is an http(s) URL.
+func isRemote(dest string) bool {
+	return strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://")
+}
+
+// loadImage reads and decodes the image file at path, unless it
+// exceeds the size limits.
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("%s: larger than %d MiB", path, maxImageBytes>>20)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("%s: %dx%d pixels is too large", path, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// layoutImage lays out an image, or a placeholder while it loads or if
+// it fails to.
+func (ui *UI) layoutImage(gtx C, img Image) D {
+	s := ui.image(ui.resolveImage(img.Dest))
+	gtx.Constraints.Min = image.Point{}
+	switch {
+	case s.err != nil:
+		return ui.layoutPlaceholder(gtx, fmt.Sprintf("%s\n(%v)", img.Alt, s.err))
+	case !s.loaded:
+		return ui.layoutPlaceholder(gtx, img.Alt)
+	}
+	return widget.Image{Src: s.op, Fit: widget.ScaleDown, Position: layout.W}.Layout(gtx)
+}
+
+// layoutPlaceholder lays out a box with a caption in place of an image.
+func (ui *UI) layoutPlaceholder(gtx C, caption string) D {
+	gtx.Constraints.Min = image.Pt(
+		min(gtx.Dp(unit.Dp(160)), gtx.Constraints.Max.X),
+		gtx.Dp(unit.Dp(90)),
+	)
+	return layout.Background{}.Layout(gtx,
+		func(gtx C) D {
+			rr := gtx.Dp(unit.Dp(4))
+			paint.FillShape(gtx.Ops, color.NRGBA(ui.Theme.Style.CodeBackground), clip.UniformRRect(image.Rectangle{Max: gtx.Constraints.Min}, rr).Op(gtx.Ops))
+			return D{Size: gtx.Constraints.Min}
+		},
+		func(gtx C) D {
+			return layout.Center.Layout(gtx, func(gtx C) D {
+				return layout.UniformInset(unit.Dp(8)).Layout(gtx, func(gtx C) D {
+					lbl := material.Caption(ui.Theme.Base, caption)
+					lbl.Alignment = text.Middle
+					return lbl.Layout(gtx)
+				})
+			})
+		},
+	)
+}
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRemoteImage(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+	ui := newTestUI()
+	s := ui.image(ui.resolveImage(srv.URL + "/image.png"))
+	if !s.loaded || s.err != errRemoteImage {
+		t.Errorf("got loaded %v, error %v, want %v", s.loaded, s.err, errRemoteImage)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("got %d requests for a remote image, want none", n)
+	}
+}
@@ -8,16 +8,44 @@ package main
 // The left pane contains a text editor for inputing raw text.
 // The right pane renders the resulting markdown document using richtext.
 //
-// Richtext is fully interactive, links can be clicked, hovered, and longpressed.
+// Richtext is fully interactive: links can be clicked, and hovering them
+// shows their URL. Links to headings, like [see](#section), go to the
+// heading, as do the entries of the outline.
+//
+// Ctrl+F finds text in the document, and Ctrl+H replaces it.
+//
+// The style button switches between the light and dark styles, and the
+// style given by -style, which can be a JSON style sheet.
+//
+// Usage:
+//
+//	markdown [-style light|dark|style.json] [file]
+//	markdown -export html,png [-width 800] [-o dir] file...
+//
+// The file is opened for editing, or created on the first save if it
+// doesn't exist. Files with a path are saved automatically.
+//
+// With -export, the files are converted to standalone HTML or to PNG
+// images of the rendered preview, without opening a window.
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"gioui.org/app"
 	"gioui.org/font"
+	"gioui.org/io/event"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
@@ -27,6 +55,7 @@ import (
 	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"gioui.org/x/component"
+	"gioui.org/x/explorer"
 	"gioui.org/x/markdown"
 	"gioui.org/x/richtext"
 
@@ -34,15 +63,65 @@ import (
 	"github.com/inkeliz/giohyperlink"
 )
 
+var (
+	export      = flag.String("export", "", "convert the files to a comma-separated list of formats, html or png, and exit")
+	exportDir   = flag.String("o", "", "write exported files to `dir` instead of next to their source")
+	exportWidth = flag.Int("width", 800, "width in pixels of exported PNG images")
+	styleName   = flag.String("style", "light", "markdown style: light, dark or the path of a JSON style sheet")
+)
+
 func main() {
+	flag.Parse()
+	r := NewRenderer()
+	r.Config.MonospaceFont.Typeface = "Go Mono"
+	style, err := LoadStyle(*styleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.SetStyle(style)
+	if *export != "" {
+		if err := convert(r, flag.Args(), strings.Split(*export, ","), *exportDir, *exportWidth); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	th := NewTheme(gofont.Collection())
-	ui := UI{
-		Window:   new(app.Window),
-		Renderer: markdown.NewRenderer(),
+	w := new(app.Window)
+	ui := &UI{
+		Window:   w,
+		Explorer: explorer.NewExplorer(w),
+		Renderer: r,
 		Theme:    th,
 		Resize:   component.Resize{Ratio: 0.5},
+		Styles:   StylePicker{Styles: []*Style{LightStyle, DarkStyle}},
+		files:    make(chan fileResult, 1),
+
+		imageResults: make(chan imageResult),
+		renders:      make(chan renderResult),
+	}
+	if path := flag.Arg(0); path != "" {
+		// Images are found relative to the absolute path.
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Fatal(err)
+		}
+		ui.Load(path, string(data))
+	} else {
+		ui.updateTitle()
 	}
-	ui.Renderer.Config.MonospaceFont.Typeface = "Go Mono"
+	if i := slices.Index(ui.Styles.Styles, style); i != -1 {
+		ui.Styles.Index = i
+	} else {
+		ui.Styles.Index = len(ui.Styles.Styles)
+		ui.Styles.Styles = append(ui.Styles.Styles, style)
+	}
+	th.SetStyle(style)
+	ui.Files.Width.SingleLine = true
+	ui.Files.Width.Filter = "0123456789"
+	ui.Files.Width.SetText(strconv.Itoa(*exportWidth))
 	go func() {
 		if err := ui.Loop(); err != nil {
 			log.Fatal(err)
@@ -62,105 +141,331 @@ type UI struct {
 	// External systems.
 	// Window provides access to the OS window.
 	Window *app.Window
+	// Explorer opens the platform dialogs for choosing files.
+	Explorer *explorer.Explorer
 	// Theme contains semantic style data. Extends `material.Theme`.
 	Theme *Theme
 	// Renderer tranforms raw text containing markdown into richtext.
-	Renderer *markdown.Renderer
+	Renderer *Renderer
 
 	// Core state.
 	// Editor retains raw text in an edit buffer.
 	Editor widget.Editor
-	// TextState retains rich text interactions: clicks, hovers and longpresses.
-	TextState richtext.InteractiveText
+	// TextState retains rich text interactions: clicks, hovers and longpresses,
+	// for each rendered block.
+	TextState []BlockState
+	// Preview scrolls the rendered blocks.
+	Preview widget.List
 	// Resize state retains the split between the editor and the rendered text.
 	component.Resize
+	// Doc tracks the file being edited.
+	Doc Document
+	// Files holds the state of the file toolbar.
+	Files FileBar
+	// Notifications shows errors over the content.
+	Notifications Notifications
+	// Tooltip shows the URL of the hovered link.
+	Tooltip Tooltip
+	// Outline lists the headings of the document.
+	Outline Outline
+	// Find holds the state of the find and replace bar.
+	Find FindBar
+	// Styles switches the style of the window and the rendering.
+	Styles StylePicker
+
+	// files receives the results of opening and saving files.
+	files chan fileResult
+	// title is the current window title.
+	title string
+	// caret is the last known caret position of the editor.
+	caret int
+	// rendering tracks the rendering of the editor text.
+	rendering renderState
+	// renders receives the results of rendering.
+	renders chan renderResult
+	// imageCache holds the images of the document by path or URL.
+	imageCache map[string]*imageState
+	// imageResults receives the images loaded in the background.
+	imageResults chan imageResult
 }
 
 // Theme contains semantic style data.
 type Theme struct {
 	// Base theme to extend.
 	Base *material.Theme
+	// Style of the rendered markdown.
+	Style *Style
 	// cache of processed markdown.
-	cache []richtext.SpanStyle
+	cache []Block
 }
 
 // NewTheme instantiates a theme, extending material theme.
 func NewTheme(font []font.FontFace) *Theme {
 	th := material.NewTheme()
 	th.Shaper = text.NewShaper(text.WithCollection(font))
-	return &Theme{
+	t := &Theme{
 		Base: th,
 	}
+	t.SetStyle(LightStyle)
+	return t
 }
 
 // Loop drives the UI until the window is destroyed.
-func (ui UI) Loop() error {
+func (ui *UI) Loop() error {
+	events := make(chan event.Event)
+	acks := make(chan struct{})
+	go func() {
+		for {
+			e := ui.Window.Event()
+			events <- e
+			<-acks
+			if _, ok := e.(app.DestroyEvent); ok {
+				return
+			}
+		}
+	}()
 	var ops op.Ops
 	for {
-		e := ui.Window.Event()
-		giohyperlink.ListenEvents(e)
-		switch e := e.(type) {
-		case app.DestroyEvent:
-			return e.Err
-		case app.FrameEvent:
-			gtx := app.NewContext(&ops, e)
-			ui.Layout(gtx)
-			e.Frame(gtx.Ops)
+		select {
+		case res := <-ui.files:
+			ui.fileDone(res)
+			ui.Window.Invalidate()
+		case res := <-ui.renders:
+			ui.render(res)
+			ui.Window.Invalidate()
+		case res := <-ui.imageResults:
+			ui.imageDone(res)
+			ui.Window.Invalidate()
+		case e := <-events:
+			giohyperlink.ListenEvents(e)
+			ui.Explorer.ListenEvents(e)
+			switch e := e.(type) {
+			case app.DestroyEvent:
+				ui.SaveOnExit()
+				acks <- struct{}{}
+				return e.Err
+			case app.FrameEvent:
+				gtx := app.NewContext(&ops, e)
+				ui.Layout(gtx)
+				e.Frame(gtx.Ops)
+			}
+			acks <- struct{}{}
 		}
 	}
 }
 
 // Update processes events from the previous frame, updating state accordingly.
 func (ui *UI) Update(gtx C) {
+	for i := range ui.TextState {
+		s := &ui.TextState[i]
+		ui.updateText(gtx, &s.Text)
+		for j := range s.Parts {
+			ui.updateText(gtx, &s.Parts[j])
+		}
+	}
 	for {
-		o, event, ok := ui.TextState.Update(gtx)
+		event, ok := ui.Editor.Update(gtx)
 		if !ok {
 			break
 		}
-		switch event.Type {
-		case richtext.Click:
-			if url, ok := o.Get(markdown.MetadataURL).(string); ok && url != "" {
-				if err := giohyperlink.Open(url); err != nil {
-					// TODO(jfm): display UI element explaining the error to the user.
-					log.Printf("error: opening hyperlink: %v", err)
-				}
-			}
-		case richtext.Hover:
-		case richtext.LongPress:
-			log.Println("longpress")
-			if url, ok := o.Get(markdown.MetadataURL).(string); ok && url != "" {
-				ui.Window.Option(app.Title(url))
-			}
+		if _, ok := event.(widget.ChangeEvent); ok {
+			ui.changed(gtx)
 		}
 	}
+	ui.updateOutline(gtx)
+	ui.updateFind(gtx)
+	ui.updateStyle(gtx)
+	if caret, _ := ui.Editor.Selection(); caret != ui.caret {
+		ui.caret = caret
+		ui.followCaret()
+	}
+	ui.updateRender(gtx)
+	ui.updateFile(gtx)
+	ui.Notifications.Update(gtx)
+	ui.updateTitle()
+}
+
+// changed schedules rendering and autosave after an edit.
+func (ui *UI) changed(gtx C) {
+	// Dirty is confirmed once the text is rendered, to avoid comparing
+	// large documents on every keystroke.
+	ui.Doc.Dirty = true
+	ui.Doc.edited = gtx.Now
+	ui.Doc.saveFailed = false
+	ui.Find.stale = true
+	ui.requestRender(gtx.Now.Add(renderDelay))
+}
+
+// updateText handles the events on the spans of rendered text.
+func (ui *UI) updateText(gtx C, state *richtext.InteractiveText) {
 	for {
-		event, ok := ui.Editor.Update(gtx)
+		o, event, ok := state.Update(gtx)
 		if !ok {
 			break
 		}
-		if _, ok := event.(widget.ChangeEvent); ok {
-			var err error
-			ui.Theme.cache, err = ui.Renderer.Render([]byte(ui.Editor.Text()))
-			if err != nil {
-				// TODO(jfm): display UI element explaining the error to the user.
-				log.Printf("error: rendering markdown: %v", err)
+		ui.handleText(gtx, o, event)
+	}
+}
+
+// handleText handles an event on a span of rendered text.
+func (ui *UI) handleText(gtx C, o *richtext.InteractiveSpan, event richtext.Event) {
+	switch event.Type {
+	case richtext.Click:
+		if m, ok := o.Get(metadataTask).(taskMarker); ok {
+			ui.toggleTask(gtx, m)
+		}
+		if url, ok := o.Get(markdown.MetadataURL).(string); ok && strings.HasPrefix(url, "#") {
+			ui.goToAnchor(gtx, url[1:])
+		} else if ok && url != "" {
+			if err := giohyperlink.Open(url); err != nil {
+				ui.Notifications.Push(fmt.Sprintf("Opening %s: %v", url, err))
 			}
 		}
+	case richtext.Hover:
+		if url, ok := o.Get(markdown.MetadataURL).(string); ok {
+			ui.Tooltip.URL = url
+		}
+	case richtext.Unhover:
+		if url, ok := o.Get(markdown.MetadataURL).(string); ok && url == ui.Tooltip.URL {
+			ui.Tooltip.URL = ""
+		}
+	}
+}
+
+// renderDelay is the pause in editing after which the text is rendered
+// again.
+const renderDelay = 100 * time.Millisecond
+
+// renderState tracks the rendering of the editor text in the
+// background.
+type renderState struct {
+	// pending is set when the text is to be rendered at time at.
+	pending bool
+	at      time.Time
+	// gen counts the renders started. Only the results of the latest
+	// are applied.
+	gen    int
+	cancel context.CancelFunc
+}
+
+// renderResult is the outcome of rendering.
+type renderResult struct {
+	gen    int
+	text   string
+	blocks []Block
+	err    error
+}
+
+// render applies the result of rendering to the markdown cache, unless
+// it is stale.
+func (ui *UI) render(res renderResult) {
+	r := &ui.rendering
+	if res.gen != r.gen {
+		// A render of newer text is under way.
+		return
 	}
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	if res.err != nil {
+		ui.Notifications.Push(res.err.Error())
+		return
+	}
+	ui.Theme.cache = res.blocks
+	ui.TextState = grow(ui.TextState[:cap(ui.TextState)], len(ui.Theme.cache))[:len(ui.Theme.cache)]
+	ui.pruneImages()
+	ui.Outline.update(res.blocks)
+	ui.Find.stale = true
+	if !r.pending {
+		// The editor still holds the rendered text.
+		ui.Doc.Dirty = res.text != ui.Doc.saved
+		ui.updateTitle()
+	}
+	ui.followCaret()
+}
+
+// requestRender schedules rendering of the editor text at the given
+// time. Requests made before then are merged.
+func (ui *UI) requestRender(at time.Time) {
+	ui.rendering.pending = true
+	ui.rendering.at = at
+}
+
+// updateRender starts the requested render once its time has come,
+// cancelling the render in progress, if any.
+func (ui *UI) updateRender(gtx C) {
+	r := &ui.rendering
+	if !r.pending {
+		return
+	}
+	if gtx.Now.Before(r.at) {
+		gtx.Execute(op.InvalidateCmd{At: r.at})
+		return
+	}
+	r.pending = false
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.gen++
+	var ctx context.Context
+	ctx, r.cancel = context.WithCancel(context.Background())
+	gen, text := r.gen, ui.Editor.Text()
+	go func() {
+		blocks, err := ui.Renderer.RenderGen(ctx, []byte(text), gen)
+		if ctx.Err() != nil {
+			return
+		}
+		ui.renders <- renderResult{gen: gen, text: text, blocks: blocks, err: err}
+	}()
 }
 
 // Layout renders the current frame.
 func (ui *UI) Layout(gtx C) D {
+	// Catch the file shortcuts wherever the focus is.
+	area := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, ui)
 	ui.Update(gtx)
+	paint.Fill(gtx.Ops, ui.Theme.Base.Bg)
+	dims := layout.Stack{Alignment: layout.SE}.Layout(gtx,
+		layout.Expanded(func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(ui.layoutFileBar),
+				layout.Rigid(ui.layoutFindBar),
+				layout.Flexed(1, func(gtx C) D {
+					return layout.Flex{}.Layout(gtx,
+						layout.Rigid(func(gtx C) D {
+							if !ui.Outline.Visible {
+								return D{}
+							}
+							return ui.layoutOutline(gtx)
+						}),
+						layout.Flexed(1, ui.layoutPanes),
+					)
+				}),
+			)
+		}),
+		layout.Stacked(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(16)).Layout(gtx, func(gtx C) D {
+				return ui.Notifications.Layout(gtx, ui.Theme.Base)
+			})
+		}),
+	)
+	area.Pop()
+	return dims
+}
+
+// layoutPanes lays out the editor and the rendered text side by side.
+func (ui *UI) layoutPanes(gtx C) D {
 	return ui.Resize.Layout(gtx,
 		func(gtx C) D {
 			return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
-				return material.Editor(ui.Theme.Base, &ui.Editor, "markdown").Layout(gtx)
+				return ui.layoutEditor(gtx)
 			})
 		},
 		func(gtx C) D {
 			return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
-				return richtext.Text(&ui.TextState, ui.Theme.Base.Shaper, ui.Theme.cache...).Layout(gtx)
+				return ui.Tooltip.Layout(gtx, ui.Theme.Base, ui.layoutPreview)
 			})
 		},
 		func(gtx C) D {
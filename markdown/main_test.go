@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"gioui.org/app"
+	"gioui.org/font/gofont"
+	"gioui.org/io/input"
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/x/component"
+)
+
+// largeDocument returns a markdown document of about n lines.
+func largeDocument(n int) string {
+	var b strings.Builder
+	for i := 0; b.Len() == 0 || strings.Count(b.String(), "\n") < n; i++ {
+		fmt.Fprintf(&b, "## Section %d\n\n", i)
+		fmt.Fprintf(&b, "Some *emphasized* and **strong** text with a [link](https://gioui.org/%d)\nwrapping over two lines.\n\n", i)
+		b.WriteString("- [ ] a task\n- [x] a done task\n  - nested item\n\n")
+		b.WriteString("```go\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n```\n\n")
+		b.WriteString("| a | b |\n|---|--:|\n| 1 | 2 |\n\n")
+	}
+	return b.String()
+}
+
+func newTestUI() *UI {
+	return &UI{
+		Window:       new(app.Window),
+		Renderer:     NewRenderer(),
+		Theme:        NewTheme(gofont.Collection()),
+		Resize:       component.Resize{Ratio: 0.5},
+		files:        make(chan fileResult, 1),
+		imageResults: make(chan imageResult),
+		renders:      make(chan renderResult),
+	}
+}
+
+// BenchmarkKeystroke measures the response of the app to keystrokes
+// in a large document, which must not wait for rendering. Every
+// iteration types a rune and deletes it again.
+func BenchmarkKeystroke(b *testing.B) {
+	ui := newTestUI()
+	ui.Load("", largeDocument(10000))
+	var (
+		ops    op.Ops
+		router input.Router
+	)
+	frame := func() {
+		ops.Reset()
+		gtx := layout.Context{
+			Ops:         &ops,
+			Now:         time.Now(),
+			Constraints: layout.Exact(image.Pt(1200, 800)),
+			Source:      router.Source(),
+		}
+		ui.Layout(gtx)
+		router.Frame(&ops)
+	}
+	// Wait for the document to be rendered.
+	for ui.rendering.pending || ui.rendering.cancel != nil {
+		frame()
+		select {
+		case res := <-ui.renders:
+			ui.render(res)
+		case <-time.After(renderDelay):
+		}
+	}
+	router.Source().Execute(key.FocusCmd{Tag: &ui.Editor})
+	frame()
+	size := ui.Editor.Len()
+	for b.Loop() {
+		// Type a rune and move the caret past it, as the platform does.
+		start, end := ui.Editor.Selection()
+		router.Queue(
+			key.EditEvent{Range: key.Range{Start: start, End: end}, Text: "x"},
+			key.SelectionEvent{Start: start + 1, End: start + 1},
+		)
+		frame()
+		router.Queue(key.Event{Name: key.NameDeleteBackward, State: key.Press})
+		frame()
+	}
+	if !ui.rendering.pending || ui.Editor.Len() != size {
+		b.Fatalf("got %d runes, pending render %v; want %d runes and a pending render", ui.Editor.Len(), ui.rendering.pending, size)
+	}
+}
+
+// BenchmarkRender measures rendering a large document in the
+// background.
+func BenchmarkRender(b *testing.B) {
+	r := NewRenderer()
+	src := []byte(largeDocument(10000))
+	ctx := context.Background()
+	for b.Loop() {
+		if _, err := r.Render(ctx, src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+const (
+	// notificationTimeout is how long notifications are shown.
+	notificationTimeout = 8 * time.Second
+	// maxNotifications bounds the notifications shown at once.
+	maxNotifications = 4
+)
+
+var (
+	notificationColor = color.NRGBA{R: 0xb7, G: 0x1c, B: 0x1c, A: 0xff}
+	tooltipColor      = color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xf0}
+)
+
+// Notification is an error shown over the content until it times out
+// or is dismissed.
+type Notification struct {
+	Text    string
+	expires time.Time
+	dismiss widget.Clickable
+}
+
+// Notifications is the notification area, in the corner of the window.
+type Notifications struct {
+	list []*Notification
+}
+
+// Push adds a notification, or restarts the timeout of an identical
+// one, so that repeated errors don't pile up.
+func (n *Notifications) Push(text string) {
+	for _, note := range n.list {
+		if note.Text == text {
+			note.expires = time.Time{}
+			return
+		}
+	}
+	if len(n.list) == maxNotifications {
+		n.list = n.list[1:]
+	}
+	n.list = append(n.list, &Notification{Text: text})
+}
+
+// Update drops the notifications that timed out or were dismissed.
+func (n *Notifications) Update(gtx C) {
+	var next time.Time
+	list := n.list[:0]
+	for _, note := range n.list {
+		if note.expires.IsZero() {
+			note.expires = gtx.Now.Add(notificationTimeout)
+		}
+		if note.dismiss.Clicked(gtx) || !gtx.Now.Before(note.expires) {
+			continue
+		}
+		if next.IsZero() || note.expires.Before(next) {
+			next = note.expires
+		}
+		list = append(list, note)
+	}
+	clear(n.list[len(list):])
+	n.list = list
+	if !next.IsZero() {
+		gtx.Execute(op.InvalidateCmd{At: next})
+	}
+}
+
+// Layout lays out the notifications from the bottom up.
+func (n *Notifications) Layout(gtx C, th *material.Theme) D {
+	gtx.Constraints.Min = image.Point{}
+	gtx.Constraints.Max.X = min(gtx.Constraints.Max.X, gtx.Dp(unit.Dp(360)))
+	children := make([]layout.FlexChild, len(n.list))
+	for i, note := range n.list {
+		children[i] = layout.Rigid(func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(8)}.Layout(gtx, func(gtx C) D {
+				return note.dismiss.Layout(gtx, func(gtx C) D {
+					return card(gtx, notificationColor, func(gtx C) D {
+						lbl := material.Body2(th, note.Text+"  ×")
+						lbl.Color = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+						return lbl.Layout(gtx)
+					})
+				})
+			})
+		})
+	}
+	return layout.Flex{Axis: layout.Vertical, Alignment: layout.End}.Layout(gtx, children...)
+}
+
+// card lays out w over a rounded background of color c.
+func card(gtx C, c color.NRGBA, w layout.Widget) D {
+	return layout.Background{}.Layout(gtx,
+		func(gtx C) D {
+			rr := gtx.Dp(unit.Dp(4))
+			paint.FillShape(gtx.Ops, c, clip.UniformRRect(image.Rectangle{Max: gtx.Constraints.Min}, rr).Op(gtx.Ops))
+			return D{Size: gtx.Constraints.Min}
+		},
+		func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(6), Bottom: unit.Dp(6), Left: unit.Dp(10), Right: unit.Dp(10)}.Layout(gtx, w)
+		},
+	)
+}
+
+// Tooltip shows the URL of the link under the pointer.
+type Tooltip struct {
+	// URL of the hovered link, if any.
+	URL string
+	// pos is the last position of the pointer.
+	pos f32.Point
+}
+
+// Layout lays out w, tracking the pointer over it, and the tooltip
+// over w next to the pointer.
+func (t *Tooltip) Layout(gtx C, th *material.Theme, w layout.Widget) D {
+	area := clip.Rect{Max: gtx.Constraints.Max}.Push(gtx.Ops)
+	event.Op(gtx.Ops, t)
+	for {
+		ev, ok := gtx.Event(pointer.Filter{Target: t, Kinds: pointer.Move | pointer.Enter})
+		if !ok {
+			break
+		}
+		if e, ok := ev.(pointer.Event); ok {
+			t.pos = e.Position
+		}
+	}
+	dims := w(gtx)
+	area.Pop()
+	if t.URL == "" {
+		return dims
+	}
+	m := op.Record(gtx.Ops)
+	lgtx := gtx
+	lgtx.Constraints.Min = image.Point{}
+	tip := card(lgtx, tooltipColor, func(gtx C) D {
+		lbl := material.Caption(th, t.URL)
+		lbl.Color = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		lbl.MaxLines = 1
+		return lbl.Layout(gtx)
+	})
+	call := m.Stop()
+	// Place the tooltip below the pointer, inside the bounds of w.
+	gap := gtx.Dp(unit.Dp(16))
+	pos := t.pos.Round().Add(image.Pt(0, gap))
+	pos.X = max(min(pos.X, dims.Size.X-tip.Size.X), 0)
+	if pos.Y+tip.Size.Y > dims.Size.Y {
+		pos.Y = max(t.pos.Round().Y-gap-tip.Size.Y, 0)
+	}
+	defer op.Offset(pos).Push(gtx.Ops).Pop()
+	call.Add(gtx.Ops)
+	return dims
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"net/url"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Outline is the collapsible pane listing the headings of the document.
+type Outline struct {
+	// Visible reports whether the pane is shown.
+	Visible bool
+	Toggle  widget.Clickable
+	List    widget.List
+	// items are the headings of the document, in order.
+	items []outlineItem
+	// collapsed holds the IDs of the headings whose subheadings are
+	// hidden.
+	collapsed map[string]bool
+}
+
+// outlineItem is a heading in the outline.
+type outlineItem struct {
+	// block is the index of the heading among the rendered blocks.
+	block   int
+	heading *Heading
+	// parent reports whether the heading has subheadings.
+	parent        bool
+	click, expand widget.Clickable
+}
+
+// update rebuilds the outline from the rendered blocks.
+func (o *Outline) update(blocks []Block) {
+	n := 0
+	for _, b := range blocks {
+		if b.Heading != nil {
+			n++
+		}
+	}
+	o.items = grow(o.items[:cap(o.items)], n)[:n]
+	n = 0
+	for i, b := range blocks {
+		if b.Heading == nil {
+			continue
+		}
+		it := &o.items[n]
+		it.block, it.heading, it.parent = i, b.Heading, false
+		if n > 0 && o.items[n-1].heading.Level < b.Heading.Level {
+			o.items[n-1].parent = true
+		}
+		n++
+	}
+}
+
+// updateOutline handles clicks on the outline.
+func (ui *UI) updateOutline(gtx C) {
+	o := &ui.Outline
+	if o.Toggle.Clicked(gtx) {
+		o.Visible = !o.Visible
+	}
+	for i := range o.items {
+		it := &o.items[i]
+		if it.expand.Clicked(gtx) {
+			if o.collapsed == nil {
+				o.collapsed = make(map[string]bool)
+			}
+			o.collapsed[it.heading.ID] = !o.collapsed[it.heading.ID]
+		}
+		if it.click.Clicked(gtx) {
+			ui.goTo(gtx, it.block)
+		}
+	}
+}
+
+// goTo scrolls the preview to the block i and moves the editor caret
+// to its source.
+func (ui *UI) goTo(gtx C, i int) {
+	start := ui.Theme.cache[i].Start
+	ui.Editor.SetCaret(start, start)
+	// The preview shows the block at the top rather than following the
+	// caret.
+	ui.caret = start
+	ui.Preview.ScrollTo(i)
+	gtx.Execute(key.FocusCmd{Tag: &ui.Editor})
+}
+
+// goToAnchor goes to the heading with the given ID, the fragment of an
+// in-document link.
+func (ui *UI) goToAnchor(gtx C, id string) {
+	if s, err := url.PathUnescape(id); err == nil {
+		id = s
+	}
+	for i, b := range ui.Theme.cache {
+		if b.Heading != nil && b.Heading.ID == id {
+			ui.goTo(gtx, i)
+			return
+		}
+	}
+	ui.Notifications.Push(fmt.Sprintf("No heading #%s", id))
+}
+
+// layoutOutline lays out the outline, with subheadings indented under
+// their headings.
+func (ui *UI) layoutOutline(gtx C) D {
+	o := &ui.Outline
+	th := ui.Theme.Base
+	gtx.Constraints.Min.X = gtx.Dp(unit.Dp(200))
+	gtx.Constraints.Max.X = gtx.Constraints.Min.X
+	border := max(gtx.Dp(unit.Dp(1)), 1)
+	rect := image.Rect(gtx.Constraints.Max.X-border, 0, gtx.Constraints.Max.X, gtx.Constraints.Max.Y)
+	paint.FillShape(gtx.Ops, color.NRGBA(ui.Theme.Style.Border), clip.Rect(rect).Op())
+	if len(o.items) == 0 {
+		return layout.UniformInset(unit.Dp(8)).Layout(gtx, material.Caption(th, "No headings").Layout)
+	}
+	// Skip the subheadings of collapsed headings.
+	var shown []int
+	hide, top := 0, o.items[0].heading.Level
+	for i, it := range o.items {
+		top = min(top, it.heading.Level)
+		if hide > 0 && it.heading.Level > hide {
+			continue
+		}
+		hide = 0
+		shown = append(shown, i)
+		if it.parent && o.collapsed[it.heading.ID] {
+			hide = it.heading.Level
+		}
+	}
+	o.List.Axis = layout.Vertical
+	return layout.UniformInset(unit.Dp(4)).Layout(gtx, func(gtx C) D {
+		return material.List(th, &o.List).Layout(gtx, len(shown), func(gtx C, i int) D {
+			it := &o.items[shown[i]]
+			indent := unit.Dp(12 * (it.heading.Level - top))
+			return layout.Inset{Left: indent}.Layout(gtx, func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+					layout.Rigid(func(gtx C) D {
+						gtx.Constraints.Min.X = gtx.Dp(unit.Dp(16))
+						if !it.parent {
+							return D{Size: gtx.Constraints.Min}
+						}
+						arrow := "▾"
+						if o.collapsed[it.heading.ID] {
+							arrow = "▸"
+						}
+						return it.expand.Layout(gtx, material.Body2(th, arrow).Layout)
+					}),
+					layout.Flexed(1, func(gtx C) D {
+						return it.click.Layout(gtx, func(gtx C) D {
+							return layout.Inset{Top: unit.Dp(2), Bottom: unit.Dp(2)}.Layout(gtx, func(gtx C) D {
+								lbl := material.Body2(th, it.heading.Title)
+								lbl.MaxLines = 1
+								return lbl.Layout(gtx)
+							})
+						})
+					}),
+				)
+			})
+		})
+	})
+}
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"image"
+	"image/color"
+	"sort"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+	"gioui.org/x/richtext"
+)
+
+// blockAt returns the index of the rendered block containing the rune
+// offset pos of the source, or the block before it if pos falls
+// between blocks.
+func (ui *UI) blockAt(pos int) int {
+	blocks := ui.Theme.cache
+	i := sort.Search(len(blocks), func(i int) bool {
+		return blocks[i].Start > pos
+	})
+	return max(i-1, 0)
+}
+
+// followCaret scrolls the preview to the block under the editor caret,
+// unless it is already in view.
+func (ui *UI) followCaret() {
+	if len(ui.Theme.cache) == 0 {
+		return
+	}
+	i := ui.blockAt(ui.caret)
+	pos := ui.Preview.Position
+	first, last := pos.First, pos.First+pos.Count-1
+	if pos.Offset > 0 {
+		// The first block is partly hidden.
+		first++
+	}
+	if pos.OffsetLast < 0 {
+		last--
+	}
+	if i < first || i > last {
+		ui.Preview.ScrollTo(i)
+	}
+}
+
+// BlockState retains the interactions with a rendered block.
+type BlockState struct {
+	Text richtext.InteractiveText
+	// Parts retains the interactions with the cells of a table, or with
+	// the text between the images of a text block.
+	Parts []richtext.InteractiveText
+}
+
+// toggleTask checks or unchecks the task list item of the marker m.
+// Markers rendered from an earlier text are ignored.
+func (ui *UI) toggleTask(gtx C, m taskMarker) {
+	if r := &ui.rendering; m.gen != r.gen || r.pending {
+		// The text changed since it was rendered, and the marker may
+		// have moved.
+		return
+	}
+	src, off := ui.Editor.Text(), m.off
+	if off+3 > len(src) || src[off] != '[' || src[off+2] != ']' {
+		return
+	}
+	mark := "x"
+	if src[off+1] != ' ' {
+		mark = " "
+	}
+	pos := utf8.RuneCountInString(src[:off+1])
+	start, end := ui.Editor.Selection()
+	ui.Editor.SetCaret(pos, pos+1)
+	ui.Editor.Insert(mark)
+	ui.Editor.SetCaret(start, end)
+	ui.changed(gtx)
+}
+
+// layoutPreview lays out the rendered blocks in a scrollable list.
+func (ui *UI) layoutPreview(gtx C) D {
+	ui.Preview.Axis = layout.Vertical
+	blocks := ui.previewBlocks()
+	return material.List(ui.Theme.Base, &ui.Preview).Layout(gtx, len(blocks), func(gtx C, i int) D {
+		in := layout.Inset{Bottom: unit.Dp(12), Right: unit.Dp(8)}
+		return in.Layout(gtx, func(gtx C) D {
+			return ui.layoutBlock(gtx, &blocks[i], &ui.TextState[i])
+		})
+	})
+}
+
+func (ui *UI) layoutBlock(gtx C, b *Block, state *BlockState) D {
+	shaper := ui.Theme.Base.Shaper
+	style := ui.Theme.Style
+	switch b.Kind {
+	case CodeBlock:
+		return layout.Background{}.Layout(gtx,
+			func(gtx C) D {
+				rr := gtx.Dp(unit.Dp(4))
+				paint.FillShape(gtx.Ops, color.NRGBA(style.CodeBackground), clip.UniformRRect(image.Rectangle{Max: gtx.Constraints.Min}, rr).Op(gtx.Ops))
+				return D{Size: gtx.Constraints.Min}
+			},
+			func(gtx C) D {
+				gtx.Constraints.Min.X = gtx.Constraints.Max.X
+				return layout.UniformInset(unit.Dp(8)).Layout(gtx, richtext.Text(&state.Text, shaper, b.Spans...).Layout)
+			},
+		)
+	case TableBlock:
+		return ui.layoutTable(gtx, b.Table, state)
+	case QuoteBlock:
+		// Draw the bar along the quote once its height is known.
+		dims := layout.Inset{Left: unit.Dp(12)}.Layout(gtx, func(gtx C) D {
+			return ui.layoutText(gtx, b, state)
+		})
+		bar := image.Rect(0, 0, gtx.Dp(unit.Dp(4)), dims.Size.Y)
+		paint.FillShape(gtx.Ops, color.NRGBA(style.QuoteBar), clip.Rect(bar).Op())
+		return dims
+	}
+	return ui.layoutText(gtx, b, state)
+}
+
+// layoutText lays out the text of a block, with its images if any.
+func (ui *UI) layoutText(gtx C, b *Block, state *BlockState) D {
+	shaper := ui.Theme.Base.Shaper
+	if len(b.Images) == 0 {
+		return richtext.Text(&state.Text, shaper, b.Spans...).Layout(gtx)
+	}
+	return ui.layoutImages(gtx, b, state)
+}
+
+// layoutImages lays out a text block with images, each between the
+// text before and after it.
+func (ui *UI) layoutImages(gtx C, b *Block, state *BlockState) D {
+	state.Parts = grow(state.Parts, len(b.Images)+1)
+	var children []layout.FlexChild
+	addText := func(i, start, end int) {
+		spans := b.Spans[start:end]
+		if len(spans) == 0 {
+			return
+		}
+		children = append(children, layout.Rigid(func(gtx C) D {
+			return richtext.Text(&state.Parts[i], ui.Theme.Base.Shaper, spans...).Layout(gtx)
+		}))
+	}
+	start := 0
+	for i, img := range b.Images {
+		addText(i, start, img.At)
+		start = img.At
+		children = append(children, layout.Rigid(func(gtx C) D {
+			return layout.Inset{Top: unit.Dp(4), Bottom: unit.Dp(4)}.Layout(gtx, func(gtx C) D {
+				return ui.layoutImage(gtx, img)
+			})
+		}))
+	}
+	addText(len(b.Images), start, len(b.Spans))
+	return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+}
+
+// grow returns s with at least n elements.
+func grow[T any](s []T, n int) []T {
+	if n > len(s) {
+		s = append(s, make([]T, n-len(s))...)
+	}
+	return s
+}
+
+// layoutTable lays out a table with borders, dividing the width among
+// the columns by the length of their longest cells.
+func (ui *UI) layoutTable(gtx C, t *Table, state *BlockState) D {
+	var lengths []int
+	cells := 0
+	for _, row := range t.Rows {
+		for j, cell := range row {
+			if j == len(lengths) {
+				lengths = append(lengths, 0)
+			}
+			n := 0
+			for _, span := range cell {
+				n += utf8.RuneCountInString(span.Content)
+			}
+			lengths[j] = max(lengths[j], n, 3)
+		}
+		cells += len(row)
+	}
+	if len(lengths) == 0 {
+		return D{}
+	}
+	state.Parts = grow(state.Parts, cells)
+	total := 0
+	for _, n := range lengths {
+		total += n
+	}
+	width := gtx.Constraints.Max.X
+	border := max(gtx.Dp(unit.Dp(1)), 1)
+	pad := gtx.Dp(unit.Dp(6))
+	// x holds the left edges of the columns, and the right edge of the
+	// table.
+	x := make([]int, len(lengths)+1)
+	for j, n := range lengths {
+		x[j+1] = x[j] + (width-border)*n/total
+	}
+	width = x[len(lengths)] + border
+
+	var y []int // the top edges of the rows
+	h := 0
+	k := 0
+	for i, row := range t.Rows {
+		y = append(y, h)
+		// Lay out the cells before their background, which needs the
+		// height of the row.
+		var calls []op.CallOp
+		rowHeight := 0
+		for j, spans := range row {
+			m := op.Record(gtx.Ops)
+			cgtx := gtx
+			cw := max(x[j+1]-x[j]-border-2*pad, 0)
+			cgtx.Constraints = layout.Constraints{Min: image.Pt(cw, 0), Max: image.Pt(cw, gtx.Constraints.Max.Y)}
+			ts := richtext.Text(&state.Parts[k], ui.Theme.Base.Shaper, spans...)
+			if j < len(t.Align) {
+				ts.Alignment = t.Align[j]
+			}
+			dims := ts.Layout(cgtx)
+			calls = append(calls, m.Stop())
+			rowHeight = max(rowHeight, dims.Size.Y)
+			k++
+		}
+		rowHeight += 2*pad + border
+		if i == 0 {
+			r := image.Rect(0, h, width, h+rowHeight)
+			paint.FillShape(gtx.Ops, color.NRGBA(ui.Theme.Style.TableHeader), clip.Rect(r).Op())
+		}
+		for j, call := range calls {
+			off := op.Offset(image.Pt(x[j]+border+pad, h+border+pad)).Push(gtx.Ops)
+			call.Add(gtx.Ops)
+			off.Pop()
+		}
+		h += rowHeight
+	}
+	h += border
+	// Draw the borders over the cells.
+	borderColor := color.NRGBA(ui.Theme.Style.Border)
+	for _, top := range append(y, h-border) {
+		paint.FillShape(gtx.Ops, borderColor, clip.Rect(image.Rect(0, top, width, top+border)).Op())
+	}
+	for _, left := range x {
+		paint.FillShape(gtx.Ops, borderColor, clip.Rect(image.Rect(left, 0, left+border, h)).Op())
+	}
+	return D{Size: image.Pt(width, h)}
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"context"
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/x/richtext"
+)
+
+// renderTasks renders src and returns the markers of its task list
+// items, as handed to toggleTask when they are clicked.
+func renderTasks(t *testing.T, ui *UI, src string, gen int) []taskMarker {
+	t.Helper()
+	blocks, err := ui.Renderer.RenderGen(context.Background(), []byte(src), gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gtx := layout.Context{Ops: new(op.Ops), Constraints: layout.Exact(image.Pt(800, 600))}
+	var markers []taskMarker
+	for _, b := range blocks {
+		// The span metadata is only available once laid out.
+		var state richtext.InteractiveText
+		richtext.Text(&state, ui.Theme.Base.Shaper, b.Spans...).Layout(gtx)
+		for i := range state.Spans {
+			if m, ok := state.Spans[i].Get(metadataTask).(taskMarker); ok {
+				markers = append(markers, m)
+			}
+		}
+	}
+	return markers
+}
+
+func TestTaskOffset(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// want are the texts starting at the markers.
+		want []string
+	}{
+		{
+			name: "plain",
+			src:  "- [ ] één\n- [x] two\n",
+			want: []string{"[ ] één", "[x] two"},
+		},
+		{
+			name: "quoted",
+			src:  "Zoë\n\n> - [x] ça\n> - [ ] va\n",
+			want: []string{"[x] ça", "[ ] va"},
+		},
+		{
+			name: "ordered",
+			src:  "1. [ ] 一\n2. [x] 二\n",
+			want: []string{"[ ] 一", "[x] 二"},
+		},
+		{
+			name: "nested",
+			src:  "- [ ] α\n  - [x] β\n    1. [ ] γ\n",
+			want: []string{"[ ] α", "[x] β", "[ ] γ"},
+		},
+		{
+			name: "not a task",
+			src:  "- [a] b\n- c [ ]\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			markers := renderTasks(t, newTestUI(), test.src, 3)
+			if len(markers) != len(test.want) {
+				t.Fatalf("got %d markers, want %d", len(markers), len(test.want))
+			}
+			for i, m := range markers {
+				if m.gen != 3 {
+					t.Errorf("marker %d: got generation %d, want 3", i, m.gen)
+				}
+				if got := test.src[m.off:]; !strings.HasPrefix(got, test.want[i]) {
+					t.Errorf("marker %d: got %q, want %q", i, got, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestToggleTask(t *testing.T) {
+	const src = "- [ ] één\n  1. [x] twee\n\n> - [ ] ça\n"
+	tests := []struct {
+		name string
+		task int
+		// edit changes the text after it is rendered.
+		edit func(ui *UI)
+		want string
+	}{
+		{
+			name: "check",
+			task: 0,
+			want: "- [x] één\n  1. [x] twee\n\n> - [ ] ça\n",
+		},
+		{
+			name: "uncheck nested after multibyte text",
+			task: 1,
+			want: "- [ ] één\n  1. [ ] twee\n\n> - [ ] ça\n",
+		},
+		{
+			name: "quoted",
+			task: 2,
+			want: "- [ ] één\n  1. [x] twee\n\n> - [x] ça\n",
+		},
+		{
+			name: "edited",
+			task: 0,
+			edit: func(ui *UI) {
+				// Another marker is where the first one was rendered.
+				ui.Editor.SetText("- [x] new\n")
+				ui.requestRender(time.Time{})
+			},
+			want: "- [x] new\n",
+		},
+		{
+			name: "rendered again",
+			task: 0,
+			edit: func(ui *UI) {
+				ui.Editor.SetText("- [ ] one\n")
+				ui.rendering.gen++
+			},
+			want: "- [ ] one\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ui := newTestUI()
+			ui.Editor.SetText(src)
+			ui.rendering.gen = 1
+			markers := renderTasks(t, ui, src, ui.rendering.gen)
+			if test.edit != nil {
+				test.edit(ui)
+			}
+			gtx := layout.Context{Ops: new(op.Ops)}
+			ui.toggleTask(gtx, markers[test.task])
+			if got := ui.Editor.Text(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,585 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gioui.org/font"
+	"gioui.org/text"
+	"gioui.org/unit"
+	"gioui.org/x/markdown"
+	"gioui.org/x/richtext"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	gtext "github.com/yuin/goldmark/text"
+)
+
+// metadataTask is the span metadata key for the taskMarker of a task
+// list item.
+const metadataTask = "task"
+
+// taskMarker locates the "[ ]" or "[x]" marker of a task list item.
+type taskMarker struct {
+	// gen is the generation of the source the item was rendered from,
+	// as passed to RenderGen.
+	gen int
+	// off is the byte offset of the marker in the source.
+	off int
+}
+
+// BlockKind is the kind of a rendered block.
+type BlockKind uint8
+
+const (
+	// TextBlock is text, such as a paragraph, heading or list.
+	TextBlock BlockKind = iota
+	// CodeBlock is highlighted code.
+	CodeBlock
+	// TableBlock is a table.
+	TableBlock
+	// QuoteBlock is a block quote, laid out as text.
+	QuoteBlock
+)
+
+// Block is a rendered top-level element of a document, such as a
+// paragraph, heading or list.
+type Block struct {
+	// Start and End are the rune offsets of the element in the source,
+	// as reported by the parser.
+	Start, End int
+	Kind       BlockKind
+	// Spans is the styled text of text and code blocks.
+	Spans []richtext.SpanStyle
+	// Images are the images of text blocks.
+	Images []Image
+	// Table is the content of table blocks.
+	Table *Table
+	// Heading describes heading blocks, for the outline.
+	Heading *Heading
+}
+
+// Heading is a heading of the document.
+type Heading struct {
+	Level int
+	Title string
+	// ID is the anchor of the heading, for links like [see](#id).
+	ID string
+}
+
+// Table is a rendered table.
+type Table struct {
+	// Align is the alignment of each column.
+	Align []text.Alignment
+	// Rows are the cells of each row, the header first.
+	Rows [][][]richtext.SpanStyle
+}
+
+// Image is an image within a text block.
+type Image struct {
+	// At is the index of the span following the image.
+	At int
+	// Dest is the path or URL of the image, as written in the source.
+	Dest string
+	Alt  string
+}
+
+// Renderer transforms markdown into richtext, one block per top-level
+// element, so that the rendering can be matched with its source.
+type Renderer struct {
+	// Config defines how the markdown elements are presented. Zero
+	// fields are given defaults. Set it before rendering, or through
+	// SetStyle.
+	Config markdown.Config
+
+	// mu serializes parsing, since renders may overlap while a
+	// cancelled one finishes, and guards the configuration and style
+	// against changes during renders.
+	mu    sync.Mutex
+	md    goldmark.Markdown
+	style *Style
+}
+
+// NewRenderer creates a ready-to-use markdown renderer.
+func NewRenderer() *Renderer {
+	return &Renderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.GFM),
+			goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		),
+		style: LightStyle,
+	}
+}
+
+// SetStyle sets the style of the following renders.
+func (r *Renderer) SetStyle(s *Style) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.style = s
+	r.Config.DefaultSize = s.TextSize
+	sizes := []*unit.Sp{&r.Config.H1Size, &r.Config.H2Size, &r.Config.H3Size, &r.Config.H4Size, &r.Config.H5Size, &r.Config.H6Size}
+	for i, sp := range sizes {
+		*sp = s.HeadingSizes[i]
+	}
+	r.Config.DefaultColor = color.NRGBA(s.Text)
+	r.Config.InteractiveColor = color.NRGBA(s.Link)
+}
+
+// Style returns the style of the renders.
+func (r *Renderer) Style() *Style {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.style
+}
+
+// Render parses src and renders its top-level elements. It stops early
+// if ctx is cancelled.
+func (r *Renderer) Render(ctx context.Context, src []byte) ([]Block, error) {
+	return r.RenderGen(ctx, src, 0)
+}
+
+// RenderGen is like Render, and marks the task list items with gen,
+// the generation of src, so that clicks on them can be matched to the
+// source they were rendered from.
+func (r *Renderer) RenderGen(ctx context.Context, src []byte, gen int) ([]Block, error) {
+	r.mu.Lock()
+	cfg, style := r.config(), r.style
+	doc := r.md.Parser().Parse(gtext.NewReader(src))
+	r.mu.Unlock()
+	var blocks []Block
+	// runes counts the runes of src up to offset bytes, for converting
+	// the byte positions of the parser.
+	var (
+		runes  int
+		offset int
+	)
+	runeOffset := func(pos int) int {
+		if pos >= offset {
+			runes += utf8.RuneCount(src[offset:pos])
+		} else {
+			runes -= utf8.RuneCount(src[pos:offset])
+		}
+		offset = pos
+		return runes
+	}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		w := newBlockWriter(cfg, style, src, gen)
+		b, err := w.block(n)
+		if err != nil {
+			return nil, fmt.Errorf("rendering markdown: %w", err)
+		}
+		if h, ok := n.(*ast.Heading); ok {
+			// The parser generates unique IDs for headings.
+			attr, _ := h.AttributeString("id")
+			id, _ := attr.([]byte)
+			b.Heading = &Heading{Level: h.Level, Title: plainText(h, src), ID: string(id)}
+		}
+		start, end := sourceRange(n)
+		b.Start, b.End = runeOffset(start), runeOffset(end)
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// HTML converts src to HTML.
+func (r *Renderer) HTML(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.md.Convert(src, &buf); err != nil {
+		return nil, fmt.Errorf("converting markdown: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// config returns the configuration with defaults for zero fields,
+// following the markdown package.
+func (r *Renderer) config() markdown.Config {
+	cfg := r.Config
+	if cfg.DefaultSize == 0 {
+		cfg.DefaultSize = 16
+	}
+	sizes := []*unit.Sp{&cfg.H6Size, &cfg.H5Size, &cfg.H4Size, &cfg.H3Size, &cfg.H2Size, &cfg.H1Size}
+	prev := cfg.DefaultSize
+	for _, sp := range sizes {
+		if *sp == 0 {
+			*sp = unit.Sp(math.Round(1.2 * float64(prev)))
+		}
+		prev = *sp
+	}
+	if cfg.DefaultColor == (color.NRGBA{}) {
+		cfg.DefaultColor = color.NRGBA{A: 255}
+	}
+	if cfg.MonospaceFont == (font.Font{}) {
+		cfg.MonospaceFont = font.Font{Typeface: "monospace"}
+	}
+	if cfg.InteractiveColor == (color.NRGBA{}) {
+		// Match the default material theme primary color.
+		cfg.InteractiveColor = color.NRGBA{R: 0x3f, G: 0x51, B: 0xb5, A: 255}
+	}
+	return cfg
+}
+
+// sourceRange returns the byte range of the source lines of n and its
+// descendants. Markers such as list bullets and code fences may fall
+// outside the range.
+func sourceRange(n ast.Node) (start, end int) {
+	start, end = -1, -1
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Type() != ast.TypeBlock {
+			return ast.WalkContinue, nil
+		}
+		if lines := n.Lines(); lines.Len() > 0 {
+			first, last := lines.At(0), lines.At(lines.Len()-1)
+			if start == -1 || first.Start < start {
+				start = first.Start
+			}
+			end = max(end, last.Stop)
+		}
+		return ast.WalkContinue, nil
+	})
+	if start == -1 {
+		// Elements without lines, such as thematic breaks, are placed
+		// after the previous element.
+		if prev := n.PreviousSibling(); prev != nil {
+			_, start = sourceRange(prev)
+		}
+		start, end = max(start, 0), max(start, 0)
+	}
+	return start, end
+}
+
+// list is the state of a list being rendered.
+type list struct {
+	ordered bool
+	index   int
+}
+
+// blockWriter renders the nodes of a block into spans.
+type blockWriter struct {
+	cfg     markdown.Config
+	style   *Style
+	src     []byte
+	gen     int // the generation of src
+	current richtext.SpanStyle
+	// saved holds the styles of the enclosing elements, restored as
+	// the elements end.
+	saved  []richtext.SpanStyle
+	spans  []richtext.SpanStyle
+	lists  []list
+	images []Image
+	// altText renders images as their alternative text.
+	altText bool
+}
+
+func newBlockWriter(cfg markdown.Config, style *Style, src []byte, gen int) *blockWriter {
+	return &blockWriter{
+		cfg:   cfg,
+		style: style,
+		src:   src,
+		gen:   gen,
+		current: richtext.SpanStyle{
+			Font:  cfg.DefaultFont,
+			Size:  cfg.DefaultSize,
+			Color: cfg.DefaultColor,
+		},
+	}
+}
+
+// block renders the top-level element n.
+func (w *blockWriter) block(n ast.Node) (Block, error) {
+	switch n := n.(type) {
+	case *ast.FencedCodeBlock:
+		w.code(n, string(n.Language(w.src)))
+		return Block{Kind: CodeBlock, Spans: w.spans}, nil
+	case *ast.CodeBlock:
+		w.code(n, "")
+		return Block{Kind: CodeBlock, Spans: w.spans}, nil
+	case *extast.Table:
+		t, err := w.table(n)
+		return Block{Kind: TableBlock, Table: t}, err
+	case *ast.Blockquote:
+		err := ast.Walk(n, w.walk)
+		return Block{Kind: QuoteBlock, Spans: w.spans, Images: w.images}, err
+	}
+	err := ast.Walk(n, w.walk)
+	return Block{Spans: w.spans, Images: w.images}, err
+}
+
+// code renders the lines of n as code in the named language.
+func (w *blockWriter) code(n ast.Node, lang string) {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := range lines.Len() {
+		line := lines.At(i)
+		b.Write(line.Value(w.src))
+	}
+	w.current.Font = w.cfg.MonospaceFont
+	highlight(lang, strings.TrimSuffix(b.String(), "\n"), func(kind tokenKind, tok string) {
+		w.current.Color = w.style.Code.color(kind)
+		w.emit(tok)
+	})
+}
+
+// table renders the cells of n, each into its own spans.
+func (w *blockWriter) table(n *extast.Table) (*Table, error) {
+	t := new(Table)
+	for _, a := range n.Alignments {
+		align := text.Start
+		switch a {
+		case extast.AlignCenter:
+			align = text.Middle
+		case extast.AlignRight:
+			align = text.End
+		}
+		t.Align = append(t.Align, align)
+	}
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		_, header := row.(*extast.TableHeader)
+		var cells [][]richtext.SpanStyle
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cw := newBlockWriter(w.cfg, w.style, w.src, w.gen)
+			cw.altText = true
+			if header {
+				cw.cfg.DefaultFont.Weight = font.Bold
+				cw.current.Font.Weight = font.Bold
+			}
+			if err := ast.Walk(cell, cw.walk); err != nil {
+				return nil, err
+			}
+			cells = append(cells, cw.spans)
+		}
+		t.Rows = append(t.Rows, cells)
+	}
+	return t, nil
+}
+
+// emit appends s in the current style.
+func (w *blockWriter) emit(s string) {
+	if s == "" {
+		return
+	}
+	span := w.current.DeepCopy()
+	span.Content = s
+	w.spans = append(w.spans, span)
+}
+
+// separate ends the text so far with n newlines, unless there is none.
+func (w *blockWriter) separate(n int) {
+	if len(w.spans) == 0 {
+		return
+	}
+	last := w.spans[len(w.spans)-1].Content
+	have := len(last) - len(strings.TrimRight(last, "\n"))
+	if have < n {
+		w.emit(strings.Repeat("\n", n-have))
+	}
+}
+
+func (w *blockWriter) walk(n ast.Node, entering bool) (ast.WalkStatus, error) {
+	switch n := n.(type) {
+	case *ast.Paragraph:
+		// The first paragraph of a list item continues its bullet.
+		if _, item := n.Parent().(*ast.ListItem); entering && !(item && n.PreviousSibling() == nil) {
+			w.separate(2)
+		}
+	case *ast.Heading:
+		if !entering {
+			w.restore()
+			break
+		}
+		w.separate(2)
+		w.save()
+		sizes := []unit.Sp{w.cfg.H1Size, w.cfg.H2Size, w.cfg.H3Size, w.cfg.H4Size, w.cfg.H5Size, w.cfg.H6Size}
+		w.current.Size = sizes[min(max(n.Level, 1), 6)-1]
+		w.current.Color = color.NRGBA(w.style.Heading)
+	case *ast.Blockquote:
+		if !entering {
+			w.restore()
+			break
+		}
+		w.save()
+		w.current.Font.Style = font.Italic
+		w.current.Color = color.NRGBA(w.style.Quote)
+	case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock:
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		// Code nested in other elements is inlined.
+		w.separate(2)
+		lang := ""
+		if n, ok := n.(*ast.FencedCodeBlock); ok {
+			lang = string(n.Language(w.src))
+		}
+		prev := w.current
+		w.code(n, lang)
+		w.current.Font, w.current.Color = prev.Font, prev.Color
+		return ast.WalkSkipChildren, nil
+	case *ast.List:
+		if entering {
+			w.separate(1)
+			w.lists = append(w.lists, list{ordered: n.IsOrdered(), index: n.Start})
+		} else {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+	case *ast.ListItem:
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		w.separate(1)
+		l := &w.lists[len(w.lists)-1]
+		indent := strings.Repeat("    ", len(w.lists)-1)
+		if l.ordered {
+			w.emit(fmt.Sprintf("%s %d. ", indent, l.index))
+			l.index++
+		} else {
+			w.emit(indent + " • ")
+		}
+	case *ast.ThematicBreak:
+		if entering {
+			w.emit(strings.Repeat("─", 24))
+		}
+	case *ast.Text:
+		if entering {
+			w.emit(string(n.Segment.Value(w.src)))
+			switch {
+			case n.HardLineBreak():
+				w.emit("\n")
+			case n.SoftLineBreak():
+				w.emit(" ")
+			}
+		}
+	case *ast.String:
+		if entering {
+			w.emit(string(n.Value))
+		}
+	case *ast.CodeSpan:
+		if !entering {
+			w.restore()
+			break
+		}
+		w.save()
+		w.current.Font = w.cfg.MonospaceFont
+	case *ast.Emphasis:
+		switch {
+		case !entering:
+			w.restore()
+		case n.Level == 2:
+			w.save()
+			w.current.Font.Weight = font.Bold
+		default:
+			w.save()
+			w.current.Font.Style = font.Italic
+		}
+	case *ast.Link:
+		if !entering {
+			w.restore()
+			break
+		}
+		w.save()
+		w.link(string(n.Destination))
+	case *ast.AutoLink:
+		if !entering {
+			w.restore()
+			break
+		}
+		w.save()
+		w.link(string(n.URL(w.src)))
+		w.emit(string(n.Label(w.src)))
+	case *ast.Image:
+		if !entering || w.altText {
+			return ast.WalkContinue, nil
+		}
+		w.images = append(w.images, Image{
+			At:   len(w.spans),
+			Dest: string(n.Destination),
+			Alt:  plainText(n, w.src),
+		})
+		return ast.WalkSkipChildren, nil
+	case *extast.TaskCheckBox:
+		if entering {
+			w.task(n)
+		}
+	case *ast.RawHTML:
+		return ast.WalkSkipChildren, nil
+	}
+	return ast.WalkContinue, nil
+}
+
+// task renders the checkbox of a task list item, which toggles its
+// marker in the source when clicked.
+func (w *blockWriter) task(n *extast.TaskCheckBox) {
+	span := w.current.DeepCopy()
+	span.Content = "□ "
+	if n.IsChecked {
+		span.Content = "■ "
+	}
+	span.Color = w.cfg.InteractiveColor
+	if off, ok := taskOffset(n, w.src); ok {
+		span.Interactive = true
+		span.Set(metadataTask, taskMarker{gen: w.gen, off: off})
+	}
+	w.spans = append(w.spans, span)
+}
+
+// taskOffset returns the byte offset in src of the marker of the task
+// check box n, which starts the first line of its paragraph.
+func taskOffset(n *extast.TaskCheckBox, src []byte) (int, bool) {
+	lines := n.Parent().Lines()
+	if lines.Len() == 0 {
+		return 0, false
+	}
+	off := lines.At(0).Start
+	return off, bytes.HasPrefix(src[off:], []byte("["))
+}
+
+// plainText returns the text of the descendants of n, without markup.
+func plainText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(src))
+		case *ast.String:
+			b.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// link styles the current text as a link to url.
+func (w *blockWriter) link(url string) {
+	w.current.Color = w.cfg.InteractiveColor
+	w.current.Interactive = true
+	w.current.Set(markdown.MetadataURL, url)
+}
+
+// save saves the current style, for an element to change it until
+// its end.
+func (w *blockWriter) save() {
+	w.saved = append(w.saved, w.current.DeepCopy())
+}
+
+// restore restores the style saved by the matching save.
+func (w *blockWriter) restore() {
+	w.current = w.saved[len(w.saved)-1]
+	w.saved = w.saved[:len(w.saved)-1]
+}
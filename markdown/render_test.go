@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+
+	"gioui.org/font"
+	"gioui.org/unit"
+	"gioui.org/x/richtext"
+)
+
+// renderSpan renders src and returns the first span containing word.
+func renderSpan(t *testing.T, src, word string) richtext.SpanStyle {
+	t.Helper()
+	blocks, err := NewRenderer().Render(context.Background(), []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blocks {
+		for _, s := range b.Spans {
+			if strings.Contains(s.Content, word) {
+				return s
+			}
+		}
+	}
+	t.Fatalf("%q: no span contains %q", src, word)
+	return richtext.SpanStyle{}
+}
+
+func TestNestedStyles(t *testing.T) {
+	light := LightStyle
+	cfg := NewRenderer().config()
+	tests := []struct {
+		name string
+		src  string
+		// word is the text whose style is checked.
+		word   string
+		weight font.Weight
+		style  font.Style
+		color  Color
+		// size is the text size, or zero for the body text size.
+		size unit.Sp
+	}{
+		{
+			name:  "link in heading",
+			src:   "# Title [x](#a) more",
+			word:  "more",
+			color: light.Heading,
+			size:  cfg.H1Size,
+		},
+		{
+			name:  "heading in quote",
+			src:   "> # Title\n>\n> after",
+			word:  "after",
+			style: font.Italic,
+			color: light.Quote,
+		},
+		{
+			name:  "after quote",
+			src:   "- > quoted\n\n  after",
+			word:  "after",
+			color: light.Text,
+		},
+		{
+			name:   "code in bold",
+			src:    "**bold `x` tail**",
+			word:   "tail",
+			weight: font.Bold,
+			color:  light.Text,
+		},
+		{
+			name:  "code in italics",
+			src:   "*it `x` tail*",
+			word:  "tail",
+			style: font.Italic,
+			color: light.Text,
+		},
+		{
+			name:   "italics in bold",
+			src:    "**bold *it* tail**",
+			word:   "tail",
+			weight: font.Bold,
+			color:  light.Text,
+		},
+		{
+			name:  "bold in italics",
+			src:   "*it **bold** tail*",
+			word:  "tail",
+			style: font.Italic,
+			color: light.Text,
+		},
+		{
+			name:   "link in bold",
+			src:    "**see [a](u) tail**",
+			word:   "tail",
+			weight: font.Bold,
+			color:  light.Text,
+		},
+		{
+			name:  "link in quote",
+			src:   "> see [a](u) here",
+			word:  "here",
+			style: font.Italic,
+			color: light.Quote,
+		},
+		{
+			name:  "autolink in quote",
+			src:   "> see <https://gioui.org> here",
+			word:  "here",
+			style: font.Italic,
+			color: light.Quote,
+		},
+		{
+			name:   "bold link",
+			src:    "[**a**](u)",
+			word:   "a",
+			weight: font.Bold,
+			color:  light.Link,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := renderSpan(t, test.src, test.word)
+			if s.Font.Weight != test.weight || s.Font.Style != test.style {
+				t.Errorf("got weight %v and style %v, want %v and %v", s.Font.Weight, s.Font.Style, test.weight, test.style)
+			}
+			if s.Color != color.NRGBA(test.color) {
+				t.Errorf("got color %v, want %v", s.Color, test.color)
+			}
+			size := test.size
+			if size == 0 {
+				size = cfg.DefaultSize
+			}
+			if s.Size != size {
+				t.Errorf("got size %v, want %v", s.Size, size)
+			}
+		})
+	}
+}
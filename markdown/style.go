@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Style is a style sheet for the rendered markdown and the window
+// around it. Styles are loaded from JSON files such as
+//
+//	{
+//		"name": "Sepia",
+//		"background": "#f4ecd8",
+//		"text": "#5b4636",
+//		"headingSizes": [28, 24, 20, 18, 16, 16]
+//	}
+//
+// where missing fields are taken from the light style.
+type Style struct {
+	Name string `json:"name"`
+	// Background and Foreground are the colors of the window.
+	Background Color `json:"background"`
+	Foreground Color `json:"foreground"`
+	// Accent is the color of buttons and of the editor selection.
+	Accent     Color `json:"accent"`
+	AccentText Color `json:"accentText"`
+	// TextSize is the size of body text. Zero means 16.
+	TextSize unit.Sp `json:"textSize"`
+	// HeadingSizes are the sizes of the heading levels. Zero sizes are
+	// 1.2 times the size of the level below.
+	HeadingSizes [6]unit.Sp `json:"headingSizes"`
+	Text         Color      `json:"text"`
+	Heading      Color      `json:"heading"`
+	Link         Color      `json:"link"`
+	Quote        Color      `json:"quote"`
+	// QuoteBar is the color of the bar along block quotes.
+	QuoteBar       Color      `json:"quoteBar"`
+	CodeBackground Color      `json:"codeBackground"`
+	Code           CodeColors `json:"code"`
+	// Border is the color of table borders and of pane separators.
+	Border      Color `json:"border"`
+	TableHeader Color `json:"tableHeader"`
+}
+
+// CodeColors are the colors of the kinds of code tokens.
+type CodeColors struct {
+	Plain   Color `json:"plain"`
+	Keyword Color `json:"keyword"`
+	String  Color `json:"string"`
+	Comment Color `json:"comment"`
+	Number  Color `json:"number"`
+}
+
+// color returns the color of tokens of the given kind.
+func (c *CodeColors) color(kind tokenKind) color.NRGBA {
+	switch kind {
+	case keywordToken:
+		return color.NRGBA(c.Keyword)
+	case stringToken:
+		return color.NRGBA(c.String)
+	case commentToken:
+		return color.NRGBA(c.Comment)
+	case numberToken:
+		return color.NRGBA(c.Number)
+	}
+	return color.NRGBA(c.Plain)
+}
+
+// Color is a color written as "#rrggbb" or "#rrggbbaa" in JSON.
+type Color color.NRGBA
+
+// MarshalJSON implements json.Marshaler.
+func (c Color) MarshalJSON() ([]byte, error) {
+	s := fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	if c.A != 0xff {
+		s += fmt.Sprintf("%02x", c.A)
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	hex, ok := strings.CutPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if !ok || err != nil || (len(hex) != 6 && len(hex) != 8) {
+		return fmt.Errorf("invalid color %q", s)
+	}
+	if len(hex) == 6 {
+		v = v<<8 | 0xff
+	}
+	*c = Color{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+	return nil
+}
+
+var (
+	// LightStyle is the default style, dark text on a light background.
+	LightStyle = &Style{
+		Name:           "Light",
+		Background:     Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		Foreground:     Color{A: 0xff},
+		Accent:         Color{R: 0x3f, G: 0x51, B: 0xb5, A: 0xff},
+		AccentText:     Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		TextSize:       16,
+		Text:           Color{A: 0xff},
+		Heading:        Color{A: 0xff},
+		Link:           Color{R: 0x3f, G: 0x51, B: 0xb5, A: 0xff},
+		Quote:          Color{R: 0x57, G: 0x60, B: 0x6a, A: 0xff},
+		QuoteBar:       Color{R: 0xd0, G: 0xd7, B: 0xde, A: 0xff},
+		CodeBackground: Color{R: 0xf6, G: 0xf8, B: 0xfa, A: 0xff},
+		Code: CodeColors{
+			Plain:   Color{R: 0x24, G: 0x29, B: 0x2e, A: 0xff},
+			Keyword: Color{R: 0xd7, G: 0x3a, B: 0x49, A: 0xff},
+			String:  Color{R: 0x03, G: 0x2f, B: 0x62, A: 0xff},
+			Comment: Color{R: 0x6a, G: 0x73, B: 0x7d, A: 0xff},
+			Number:  Color{R: 0x00, G: 0x5c, B: 0xc5, A: 0xff},
+		},
+		Border:      Color{R: 0xd0, G: 0xd7, B: 0xde, A: 0xff},
+		TableHeader: Color{R: 0xf6, G: 0xf8, B: 0xfa, A: 0xff},
+	}
+	// DarkStyle is light text on a dark background.
+	DarkStyle = &Style{
+		Name:           "Dark",
+		Background:     Color{R: 0x0d, G: 0x11, B: 0x17, A: 0xff},
+		Foreground:     Color{R: 0xe6, G: 0xed, B: 0xf3, A: 0xff},
+		Accent:         Color{R: 0x1f, G: 0x6f, B: 0xeb, A: 0xff},
+		AccentText:     Color{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		TextSize:       16,
+		Text:           Color{R: 0xe6, G: 0xed, B: 0xf3, A: 0xff},
+		Heading:        Color{R: 0xf0, G: 0xf6, B: 0xfc, A: 0xff},
+		Link:           Color{R: 0x58, G: 0xa6, B: 0xff, A: 0xff},
+		Quote:          Color{R: 0x8b, G: 0x94, B: 0x9e, A: 0xff},
+		QuoteBar:       Color{R: 0x30, G: 0x36, B: 0x3d, A: 0xff},
+		CodeBackground: Color{R: 0x16, G: 0x1b, B: 0x22, A: 0xff},
+		Code: CodeColors{
+			Plain:   Color{R: 0xe6, G: 0xed, B: 0xf3, A: 0xff},
+			Keyword: Color{R: 0xff, G: 0x7b, B: 0x72, A: 0xff},
+			String:  Color{R: 0xa5, G: 0xd6, B: 0xff, A: 0xff},
+			Comment: Color{R: 0x8b, G: 0x94, B: 0x9e, A: 0xff},
+			Number:  Color{R: 0x79, G: 0xc0, B: 0xff, A: 0xff},
+		},
+		Border:      Color{R: 0x30, G: 0x36, B: 0x3d, A: 0xff},
+		TableHeader: Color{R: 0x16, G: 0x1b, B: 0x22, A: 0xff},
+	}
+)
+
+// LoadStyle returns the built-in style of the given name, light or
+// dark, or else loads the style from the JSON file at name.
+func LoadStyle(name string) (*Style, error) {
+	for _, s := range []*Style{LightStyle, DarkStyle} {
+		if strings.EqualFold(name, s.Name) {
+			return s, nil
+		}
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	s := *LightStyle
+	s.Name = ""
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	return &s, nil
+}
+
+// StylePicker switches between the available styles.
+type StylePicker struct {
+	Switch widget.Clickable
+	// Styles are the styles to switch between, and Index is the one in
+	// use.
+	Styles []*Style
+	Index  int
+}
+
+// SetStyle applies s to the theme.
+func (t *Theme) SetStyle(s *Style) {
+	t.Style = s
+	t.Base.Palette = material.Palette{
+		Bg:         color.NRGBA(s.Background),
+		Fg:         color.NRGBA(s.Foreground),
+		ContrastBg: color.NRGBA(s.Accent),
+		ContrastFg: color.NRGBA(s.AccentText),
+	}
+}
+
+// setStyle switches the window and the rendering to s.
+func (ui *UI) setStyle(s *Style) {
+	ui.Theme.SetStyle(s)
+	ui.Renderer.SetStyle(s)
+	ui.requestRender(time.Time{})
+}
+
+// updateStyle switches to the next style when asked.
+func (ui *UI) updateStyle(gtx C) {
+	p := &ui.Styles
+	if p.Switch.Clicked(gtx) && len(p.Styles) > 0 {
+		p.Index = (p.Index + 1) % len(p.Styles)
+		ui.setStyle(p.Styles[p.Index])
+	}
+}
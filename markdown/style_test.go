@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Unlicense OR MIT
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestColorJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want Color
+		err  bool
+	}{
+		{json: `"#3f51b5"`, want: Color{R: 0x3f, G: 0x51, B: 0xb5, A: 0xff}},
+		{json: `"#3F51B580"`, want: Color{R: 0x3f, G: 0x51, B: 0xb5, A: 0x80}},
+		{json: `"#000000"`, want: Color{A: 0xff}},
+		{json: `"3f51b5"`, err: true},
+		{json: `"#3f51b"`, err: true},
+		{json: `"#3f51b5f"`, err: true},
+		{json: `"#3f51bg"`, err: true},
+		{json: `"#-3f51b5"`, err: true},
+		{json: `"#"`, err: true},
+		{json: `16`, err: true},
+	}
+	for _, test := range tests {
+		var c Color
+		err := json.Unmarshal([]byte(test.json), &c)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", test.json, c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.json, err)
+			continue
+		}
+		if c != test.want {
+			t.Errorf("%s: got %v, want %v", test.json, c, test.want)
+		}
+		// Colors survive the round trip.
+		data, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c2 Color
+		if err := json.Unmarshal(data, &c2); err != nil || c2 != c {
+			t.Errorf("%s: marshalled to %s, which unmarshals to %v, %v", test.json, data, c2, err)
+		}
+	}
+}
+
+func TestLoadStyle(t *testing.T) {
+	for _, name := range []string{"light", "Dark"} {
+		if _, err := LoadStyle(name); err != nil {
+			t.Errorf("built-in style %q: %v", name, err)
+		}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sepia.json")
+	data := `{"background": "#f4ecd8", "code": {"keyword": "#aa0000"}, "headingSizes": [28]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadStyle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "sepia" {
+		t.Errorf("got name %q, want the file name", s.Name)
+	}
+	if want := (Color{R: 0xf4, G: 0xec, B: 0xd8, A: 0xff}); s.Background != want {
+		t.Errorf("got background %v, want %v", s.Background, want)
+	}
+	if want := (Color{R: 0xaa, A: 0xff}); s.Code.Keyword != want {
+		t.Errorf("got keyword color %v, want %v", s.Code.Keyword, want)
+	}
+	// Missing fields are those of the light style.
+	if s.Text != LightStyle.Text || s.Link != LightStyle.Link || s.TextSize != LightStyle.TextSize {
+		t.Errorf("got text %v, link %v, size %v, want those of the light style", s.Text, s.Link, s.TextSize)
+	}
+	if s.Code.String != LightStyle.Code.String {
+		t.Errorf("got string color %v, want %v of the light style", s.Code.String, LightStyle.Code.String)
+	}
+	if s.HeadingSizes[0] != 28 || s.HeadingSizes[1] != LightStyle.HeadingSizes[1] {
+		t.Errorf("got heading sizes %v", s.HeadingSizes)
+	}
+	if LightStyle.Background == s.Background {
+		t.Error("loading a style changed the light style")
+	}
+
+	named := filepath.Join(dir, "named.json")
+	if err := os.WriteFile(named, []byte(`{"name": "Night"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := LoadStyle(named); err != nil || s.Name != "Night" {
+		t.Errorf("got style %v, %v, want the name Night", s, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"text": "black"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadStyle(invalid); err == nil {
+		t.Error("loaded a style with an invalid color")
+	}
+	if _, err := LoadStyle(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("loaded a missing style")
+	}
+}